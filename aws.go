@@ -0,0 +1,72 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/pkg/errors"
+)
+
+// DefaultAWSTimeout limits how long each AWS call waits when no timeout is set, so that a hung
+// endpoint can't block loading forever.
+const DefaultAWSTimeout = 30 * time.Second
+
+// AWSConfig overrides the settings used to create an AWS session. Empty fields use the SDK
+// defaults, so the zero value uses the region in AWS_REGION or the shared config files and the
+// standard endpoints.
+type AWSConfig struct {
+	// Region is the AWS region, for example "us-east-1".
+	Region string
+
+	// Endpoint replaces the service endpoint, for example "http://localhost:4566" for LocalStack.
+	Endpoint string
+
+	// Timeout limits how long each call waits for a response. Zero uses DefaultAWSTimeout and a
+	// negative value only uses the deadline of the context passed to the call.
+	Timeout time.Duration
+}
+
+// callContext returns the context for a single AWS call, limited by the timeout.
+func callContext(ctx context.Context, timeout time.Duration) (context.Context,
+	context.CancelFunc) {
+
+	if timeout == 0 {
+		timeout = DefaultAWSTimeout
+	}
+
+	if timeout < 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// noSDKRetries is the client config used with sessions given by callers, so that the SDK's own
+// retries don't multiply the attempts of the RetryPolicy.
+var noSDKRetries = aws.NewConfig().WithMaxRetries(0)
+
+// newAWSSession creates a session from the config, along with any shared config files. The SDK's
+// own retries are disabled.
+func newAWSSession(cfg AWSConfig) (*session.Session, error) {
+	// Retries are handled by the RetryPolicy of the caller, so that MaxAttempts is the total
+	// number of requests.
+	awsConfig := aws.Config{MaxRetries: aws.Int(0)}
+	if len(cfg.Region) > 0 {
+		awsConfig.Region = aws.String(cfg.Region)
+	}
+	if len(cfg.Endpoint) > 0 {
+		awsConfig.Endpoint = aws.String(cfg.Endpoint)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "new session")
+	}
+
+	return sess, nil
+}
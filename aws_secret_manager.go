@@ -2,39 +2,204 @@ package config
 
 import (
 	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/pkg/errors"
 )
 
-// AWSSecretManager is used to connect to the AWS Secrets Manager service.
+// AWSSecretManager is used to connect to the AWS Secrets Manager service. The client is created
+// once and reused for every call. Failed calls are retried with DefaultRetryPolicy unless
+// SetRetryPolicy is used.
 type AWSSecretManager struct {
+	config AWSConfig
+	retry  RetryPolicy
+
+	client     secretsmanageriface.SecretsManagerAPI
+	clientLock sync.Mutex
 }
 
-// NewAWSSecretManager returns an AWSSecretManager
+// NewAWSSecretManager returns an AWSSecretManager that uses the default AWS settings.
 func NewAWSSecretManager() *AWSSecretManager {
-	return &AWSSecretManager{}
+	return NewAWSSecretManagerWithConfig(AWSConfig{})
+}
+
+// NewAWSSecretManagerWithConfig returns an AWSSecretManager that creates its client from cfg when
+// it is first used.
+func NewAWSSecretManagerWithConfig(cfg AWSConfig) *AWSSecretManager {
+	return &AWSSecretManager{
+		config: cfg,
+		retry:  DefaultRetryPolicy,
+	}
+}
+
+// NewAWSSecretManagerWithSession returns an AWSSecretManager that uses a client created from
+// sess. The SDK's own retries are disabled for the client since calls are retried by the
+// RetryPolicy.
+func NewAWSSecretManagerWithSession(sess client.ConfigProvider) *AWSSecretManager {
+	return NewAWSSecretManagerWithClient(secretsmanager.New(sess, noSDKRetries))
+}
+
+// NewAWSSecretManagerWithClient returns an AWSSecretManager that uses client, which can be any
+// implementation of the Secrets Manager API.
+//
+// Any retries done by the client are repeated for each attempt of the RetryPolicy, so a client
+// from the SDK should be created with MaxRetries set to 0, or the RetryPolicy should be replaced
+// with SetRetryPolicy.
+func NewAWSSecretManagerWithClient(
+	client secretsmanageriface.SecretsManagerAPI) *AWSSecretManager {
+
+	return &AWSSecretManager{
+		client: client,
+		retry:  DefaultRetryPolicy,
+	}
+}
+
+// SetTimeout sets the limit on how long each call waits for a response. It replaces the Timeout of
+// the AWSConfig and should be set before the AWSSecretManager is used.
+func (s *AWSSecretManager) SetTimeout(timeout time.Duration) {
+	s.config.Timeout = timeout
+}
+
+// SetRetryPolicy sets how failed calls are retried. It should be set before the AWSSecretManager
+// is used.
+func (s *AWSSecretManager) SetRetryPolicy(policy RetryPolicy) {
+	s.retry = policy
 }
 
-// Get returns the contents of a secret.
+// SecretRequest selects a secret and optionally a version of it.
+type SecretRequest struct {
+	Name string
+
+	// Stage is a version stage such as "AWSCURRENT", "AWSPENDING" or "AWSPREVIOUS".
+	Stage string
+
+	// VersionID pins the request to a specific version.
+	VersionID string
+}
+
+// SecretVersion is the value of a secret along with the version it came from.
+type SecretVersion struct {
+	Value []byte
+
+	// VersionID is the version that was returned, so that it can be logged.
+	VersionID string
+
+	// Stages are the version stages attached to the version.
+	Stages []string
+}
+
+// Get returns the contents of a secret. The request is cancelled when ctx is done or the timeout
+// passes.
+//
+// The name can be followed by a query that selects a version, for example
+// "payments?stage=AWSPENDING" or "payments?version=EXAMPLE1-90ab-cdef". Use GetSecret to get
+// the version ID that was used.
 func (s *AWSSecretManager) Get(ctx context.Context, name string) ([]byte, error) {
+	request, err := parseSecretRequest(name)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.GetSecret(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return version.Value, nil
+}
+
+// GetSecret returns the contents of a secret along with the version ID used. Without a stage or
+// version ID the current version is returned.
+func (s *AWSSecretManager) GetSecret(ctx context.Context,
+	request SecretRequest) (*SecretVersion, error) {
+
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
 	// build the input
 	in := secretsmanager.GetSecretValueInput{
-		SecretId: &name,
+		SecretId: aws.String(request.Name),
+	}
+	if len(request.Stage) > 0 {
+		in.VersionStage = aws.String(request.Stage)
+	}
+	if len(request.VersionID) > 0 {
+		in.VersionId = aws.String(request.VersionID)
 	}
 
 	// make the request
-	out, err := s.client().GetSecretValue(&in)
-	if err != nil {
+	var out *secretsmanager.GetSecretValueOutput
+	if err := s.retry.Do(ctx, "secret "+request.Name, func(ctx context.Context) error {
+		callCtx, cancel := callContext(ctx, s.config.Timeout)
+		defer cancel()
+
+		var err error
+		out, err = client.GetSecretValueWithContext(callCtx, &in)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
 	// the secret may represent JSON, or primitive values, so return bytes
-	return []byte(*out.SecretString), nil
+	return &SecretVersion{
+		Value:     []byte(aws.StringValue(out.SecretString)),
+		VersionID: aws.StringValue(out.VersionId),
+		Stages:    aws.StringValueSlice(out.VersionStages),
+	}, nil
+}
+
+// parseSecretRequest converts a secret name with an optional stage and version query into a
+// SecretRequest.
+func parseSecretRequest(name string) (SecretRequest, error) {
+	i := strings.Index(name, "?")
+	if i < 0 {
+		return SecretRequest{Name: name}, nil
+	}
+
+	query, err := url.ParseQuery(name[i+1:])
+	if err != nil {
+		return SecretRequest{}, errors.Wrap(err, "secret query")
+	}
+
+	result := SecretRequest{Name: name[:i]}
+	for key := range query {
+		switch key {
+		case "stage":
+			result.Stage = query.Get(key)
+		case "version":
+			result.VersionID = query.Get(key)
+		default:
+			return SecretRequest{}, errors.Errorf("unknown secret query %s", key)
+		}
+	}
+
+	return result, nil
 }
 
-// client returns a client for communicating with the AWS Secrets Manager
-// service.
-func (s *AWSSecretManager) client() *secretsmanager.SecretsManager {
-	return secretsmanager.New(session.New())
+// getClient returns a client for communicating with the AWS Secrets Manager service, creating it
+// on first use. A failure to create the client is retried on the next call.
+func (s *AWSSecretManager) getClient() (secretsmanageriface.SecretsManagerAPI, error) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	sess, err := newAWSSession(s.config)
+	if err != nil {
+		return nil, errors.Wrap(err, "secrets manager")
+	}
+
+	s.client = secretsmanager.New(sess)
+	return s.client, nil
 }
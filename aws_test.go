@@ -0,0 +1,563 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// testAWSPageSize is the number of parameters returned in each page by testAWSServer, so that
+// pagination is tested with only a few parameters.
+const testAWSPageSize = 2
+
+// testSecretVersion is a version of a secret held by testAWSServer.
+type testSecretVersion struct {
+	id     string
+	value  string
+	stages []string
+}
+
+// testAWSServer is an HTTP server that implements the ParamStore and Secrets Manager calls used by
+// the package, with values held in memory.
+type testAWSServer struct {
+	*httptest.Server
+
+	lock       sync.Mutex
+	parameters map[string]string
+	secrets    map[string][]testSecretVersion
+	calls      map[string]int
+
+	// hang makes the server wait for requests to be cancelled instead of responding. Waiting
+	// requests are released when the test ends.
+	hang    bool
+	release chan struct{}
+
+	// failures is the number of requests to fail with failStatus and failCode before responding
+	// normally.
+	failures   int
+	failStatus int
+	failCode   string
+}
+
+// newTestAWSServer starts a testAWSServer and sets credentials so that requests can be signed.
+func newTestAWSServer(t *testing.T) *testAWSServer {
+	setTestEnv(t, "AWS_ACCESS_KEY_ID", "test")
+	setTestEnv(t, "AWS_SECRET_ACCESS_KEY", "test")
+	setTestEnv(t, "AWS_REGION", "us-east-1")
+
+	result := &testAWSServer{
+		parameters: make(map[string]string),
+		secrets:    make(map[string][]testSecretVersion),
+		calls:      make(map[string]int),
+		release:    make(chan struct{}),
+	}
+	result.Server = httptest.NewServer(http.HandlerFunc(result.handle))
+	t.Cleanup(result.Close)
+	t.Cleanup(func() { close(result.release) }) // cleanups run in reverse order
+
+	return result
+}
+
+// paramStore returns a ParamStore that uses the server.
+func (s *testAWSServer) paramStore() *ParamStore {
+	result := NewParamStore(AWSConfig{Region: "us-east-1", Endpoint: s.URL})
+	result.SetRetryPolicy(testRetryPolicy)
+	return result
+}
+
+// secretManager returns an AWSSecretManager that uses the server.
+func (s *testAWSServer) secretManager() *AWSSecretManager {
+	result := NewAWSSecretManagerWithConfig(AWSConfig{Region: "us-east-1", Endpoint: s.URL})
+	result.SetRetryPolicy(testRetryPolicy)
+	return result
+}
+
+func (s *testAWSServer) setParameter(name, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.parameters[name] = value
+}
+
+func (s *testAWSServer) setSecret(name, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.secrets[name] = []testSecretVersion{{id: "v1", value: value, stages: []string{"AWSCURRENT"}}}
+}
+
+// addSecretVersion adds a version of a secret. Stages are not removed from existing versions.
+func (s *testAWSServer) addSecretVersion(name, id, value string, stages ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.secrets[name] = append(s.secrets[name], testSecretVersion{
+		id:     id,
+		value:  value,
+		stages: stages,
+	})
+}
+
+func (s *testAWSServer) setHang(hang bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.hang = hang
+}
+
+func (s *testAWSServer) fail(count, status int, code string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.failures = count
+	s.failStatus = status
+	s.failCode = code
+}
+
+func (s *testAWSServer) callCount(operation string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.calls[operation]
+}
+
+func (s *testAWSServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	target := r.Header.Get("X-Amz-Target")
+	operation := target[strings.Index(target, ".")+1:]
+	s.calls[operation]++
+
+	if s.hang {
+		s.lock.Unlock()
+		select {
+		case <-r.Context().Done():
+		case <-s.release:
+		}
+		s.lock.Lock()
+		return
+	}
+
+	if s.failures > 0 {
+		s.failures--
+		writeTestAWSError(w, s.failStatus, s.failCode, "test failure")
+		return
+	}
+
+	var input map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeTestAWSError(w, http.StatusBadRequest, "SerializationException", err.Error())
+		return
+	}
+
+	switch operation {
+	case "GetParameter":
+		name, _ := input["Name"].(string)
+		value, ok := s.parameters[name]
+		if !ok {
+			writeTestAWSError(w, http.StatusBadRequest, "ParameterNotFound", name)
+			return
+		}
+
+		writeTestAWSResponse(w, map[string]interface{}{
+			"Parameter": testAWSParameter(name, value),
+		})
+
+	case "GetParametersByPath":
+		path, _ := input["Path"].(string)
+		prefix := strings.TrimSuffix(path, "/") + "/"
+
+		var names []string
+		for name := range s.parameters {
+			if strings.HasPrefix(name, prefix) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		start := 0
+		if token, ok := input["NextToken"].(string); ok {
+			start, _ = strconv.Atoi(token)
+		}
+
+		end := start + testAWSPageSize
+		response := make(map[string]interface{})
+		if end < len(names) {
+			response["NextToken"] = strconv.Itoa(end)
+		} else {
+			end = len(names)
+		}
+
+		var parameters []interface{}
+		for _, name := range names[start:end] {
+			parameters = append(parameters, testAWSParameter(name, s.parameters[name]))
+		}
+		response["Parameters"] = parameters
+
+		writeTestAWSResponse(w, response)
+
+	case "GetSecretValue":
+		name, _ := input["SecretId"].(string)
+		id, _ := input["VersionId"].(string)
+		stage, _ := input["VersionStage"].(string)
+		if len(id) == 0 && len(stage) == 0 {
+			stage = "AWSCURRENT"
+		}
+
+		for _, version := range s.secrets[name] {
+			if len(id) > 0 && version.id != id {
+				continue
+			}
+			if len(stage) > 0 && !testHasStage(version.stages, stage) {
+				continue
+			}
+
+			writeTestAWSResponse(w, map[string]interface{}{
+				"Name":          name,
+				"SecretString":  version.value,
+				"VersionId":     version.id,
+				"VersionStages": version.stages,
+			})
+			return
+		}
+
+		writeTestAWSError(w, http.StatusBadRequest, "ResourceNotFoundException", name)
+
+	default:
+		writeTestAWSError(w, http.StatusBadRequest, "UnknownOperationException", target)
+	}
+}
+
+func testHasStage(stages []string, stage string) bool {
+	for _, s := range stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+func testAWSParameter(name, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":    name,
+		"Value":   value,
+		"Type":    "SecureString",
+		"Version": 1,
+	}
+}
+
+func writeTestAWSResponse(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeTestAWSError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"__type": %q, "message": %q}`, code, message)
+}
+
+func TestParamStore_Endpoint(t *testing.T) {
+	server := newTestAWSServer(t)
+	server.setParameter("/svc/config", `{"name": "from-param-store", "db": {"port": 6543}}`)
+
+	paramStore := server.paramStore()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		cfg := &testLayeredConfig{}
+		if err := LoadParamStore("/svc/config", cfg, WithParamStore(paramStore)); err != nil {
+			t.Fatalf("Failed to load param store : %s", err)
+		}
+
+		if cfg.Name != "from-param-store" {
+			t.Errorf("Wrong name : got %s, want %s", cfg.Name, "from-param-store")
+		}
+
+		if cfg.DB.Port != 6543 {
+			t.Errorf("Wrong db port : got %d, want %d", cfg.DB.Port, 6543)
+		}
+	}
+
+	client := paramStore.client
+	if _, err := paramStore.Parameter(ctx, "/svc/config"); err != nil {
+		t.Fatalf("Failed to get parameter : %s", err)
+	}
+	if paramStore.client != client {
+		t.Errorf("Client should be reused")
+	}
+
+	if count := server.callCount("GetParameter"); count != 3 {
+		t.Errorf("Wrong GetParameter calls : got %d, want %d", count, 3)
+	}
+
+	if _, err := paramStore.Parameter(ctx, "/svc/missing"); err == nil {
+		t.Errorf("Missing parameter should fail")
+	}
+}
+
+func TestParamStore_ParametersByPath(t *testing.T) {
+	server := newTestAWSServer(t)
+	for i := 0; i < 5; i++ {
+		server.setParameter(fmt.Sprintf("/svc/prod/%d", i), strconv.Itoa(i))
+	}
+	server.setParameter("/svc/production/other", "other")
+
+	params, err := server.paramStore().ParametersByPath(context.Background(),
+		"/svc/prod")
+	if err != nil {
+		t.Fatalf("Failed to get parameters by path : %s", err)
+	}
+
+	if len(params) != 5 {
+		t.Errorf("Wrong parameter count : got %d, want %d", len(params), 5)
+	}
+
+	if count := server.callCount("GetParametersByPath"); count != 3 {
+		t.Errorf("Wrong page requests : got %d, want %d", count, 3)
+	}
+}
+
+func TestAWSSecretManager_Endpoint(t *testing.T) {
+	server := newTestAWSServer(t)
+	server.setSecret("payments", `{"api_key": "secret"}`)
+
+	secrets := server.secretManager()
+	ctx := context.Background()
+
+	got, err := secrets.Get(ctx, "payments")
+	if err != nil {
+		t.Fatalf("Failed to get secret : %s", err)
+	}
+
+	if string(got) != `{"api_key": "secret"}` {
+		t.Errorf("Wrong secret : got %s, want %s", got, `{"api_key": "secret"}`)
+	}
+
+	client := secrets.client
+	if _, err := secrets.Get(ctx, "payments"); err != nil {
+		t.Fatalf("Failed to get secret : %s", err)
+	}
+	if secrets.client != client {
+		t.Errorf("Client should be reused")
+	}
+
+	if _, err := secrets.Get(ctx, "missing"); err == nil {
+		t.Errorf("Missing secret should fail")
+	}
+}
+
+func TestParamStore_Timeout(t *testing.T) {
+	server := newTestAWSServer(t)
+	server.setParameter("/svc/config", `{"name": "from-param-store"}`)
+	server.setHang(true)
+
+	paramStore := server.paramStore()
+	paramStore.SetTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	cfg := &testLayeredConfig{}
+	if err := LoadParamStore("/svc/config", cfg, WithParamStore(paramStore)); err == nil {
+		t.Fatalf("Load from hung endpoint should fail")
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Timeout not applied : took %s", elapsed)
+	}
+
+	if _, err := paramStore.ParametersByPath(context.Background(), "/svc"); err == nil {
+		t.Errorf("Get by path from hung endpoint should fail")
+	}
+}
+
+func TestAWSSecretManager_Cancel(t *testing.T) {
+	server := newTestAWSServer(t)
+	server.setSecret("payments", `{"api_key": "secret"}`)
+	server.setHang(true)
+
+	secrets := server.secretManager()
+	secrets.SetTimeout(-1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := secrets.Get(ctx, "payments"); err == nil {
+		t.Fatalf("Get from hung endpoint should fail")
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Cancel not applied : took %s", elapsed)
+	}
+}
+
+func TestParamStore_Retry(t *testing.T) {
+	server := newTestAWSServer(t)
+	server.setParameter("/svc/config", `{"name": "from-param-store"}`)
+	server.fail(2, http.StatusBadRequest, "ThrottlingException")
+
+	cfg := &testLayeredConfig{}
+	if err := LoadParamStore("/svc/config", cfg, WithParamStore(server.paramStore())); err != nil {
+		t.Fatalf("Failed to load param store : %s", err)
+	}
+
+	if cfg.Name != "from-param-store" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "from-param-store")
+	}
+
+	if count := server.callCount("GetParameter"); count != 3 {
+		t.Errorf("Wrong GetParameter calls : got %d, want %d", count, 3)
+	}
+
+	server.fail(10, http.StatusInternalServerError, "InternalServerError")
+	if err := LoadParamStore("/svc/config", cfg, WithParamStore(server.paramStore())); err == nil {
+		t.Errorf("Load should fail after max attempts")
+	}
+
+	if count := server.callCount("GetParameter"); count != 3+testRetryPolicy.MaxAttempts {
+		t.Errorf("Wrong GetParameter calls : got %d, want %d", count,
+			3+testRetryPolicy.MaxAttempts)
+	}
+}
+
+func TestParamStore_SessionRetries(t *testing.T) {
+	server := newTestAWSServer(t)
+	server.setParameter("/svc/db", "password")
+	server.fail(10, http.StatusInternalServerError, "InternalServerError")
+
+	// The session keeps the SDK default of 3 retries, which must not be added to the policy.
+	sess, err := session.NewSession(aws.NewConfig().WithRegion("us-east-1").
+		WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create session : %s", err)
+	}
+
+	paramStore := NewParamStoreWithSession(sess)
+	paramStore.SetRetryPolicy(testRetryPolicy)
+	if _, err := paramStore.Get(context.Background(), "/svc/db"); err == nil {
+		t.Errorf("Get should fail after max attempts")
+	}
+
+	if count := server.callCount("GetParameter"); count != testRetryPolicy.MaxAttempts {
+		t.Errorf("Wrong GetParameter calls : got %d, want %d", count, testRetryPolicy.MaxAttempts)
+	}
+
+	secretManager := NewAWSSecretManagerWithSession(sess)
+	secretManager.SetRetryPolicy(testRetryPolicy)
+	if _, err := secretManager.Get(context.Background(), "payments"); err == nil {
+		t.Errorf("Get secret should fail after max attempts")
+	}
+
+	if count := server.callCount("GetSecretValue"); count != testRetryPolicy.MaxAttempts {
+		t.Errorf("Wrong GetSecretValue calls : got %d, want %d", count,
+			testRetryPolicy.MaxAttempts)
+	}
+}
+
+func TestAWSSecretManager_Retry(t *testing.T) {
+	server := newTestAWSServer(t)
+	server.setSecret("payments", "secret")
+	server.fail(1, http.StatusServiceUnavailable, "ServiceUnavailable")
+
+	got, err := server.secretManager().Get(context.Background(), "payments")
+	if err != nil {
+		t.Fatalf("Failed to get secret : %s", err)
+	}
+
+	if string(got) != "secret" {
+		t.Errorf("Wrong secret : got %s, want %s", got, "secret")
+	}
+
+	if count := server.callCount("GetSecretValue"); count != 2 {
+		t.Errorf("Wrong GetSecretValue calls : got %d, want %d", count, 2)
+	}
+}
+
+// Secrets Manager version IDs have a minimum length of 32.
+const (
+	testVersion1 = "00000000-0000-0000-0000-000000000001"
+	testVersion2 = "00000000-0000-0000-0000-000000000002"
+	testVersion3 = "00000000-0000-0000-0000-000000000003"
+)
+
+func TestAWSSecretManager_Versions(t *testing.T) {
+	server := newTestAWSServer(t)
+	server.addSecretVersion("payments", testVersion1, "old", "AWSPREVIOUS")
+	server.addSecretVersion("payments", testVersion2, "current", "AWSCURRENT")
+	server.addSecretVersion("payments", testVersion3, "pending", "AWSPENDING")
+
+	secrets := server.secretManager()
+	ctx := context.Background()
+
+	tests := []struct {
+		request SecretRequest
+		value   string
+		version string
+	}{
+		{request: SecretRequest{Name: "payments"}, value: "current", version: testVersion2},
+		{request: SecretRequest{Name: "payments", Stage: "AWSPENDING"}, value: "pending",
+			version: testVersion3},
+		{request: SecretRequest{Name: "payments", Stage: "AWSPREVIOUS"}, value: "old",
+			version: testVersion1},
+		{request: SecretRequest{Name: "payments", VersionID: testVersion3}, value: "pending",
+			version: testVersion3},
+	}
+
+	for _, tt := range tests {
+		got, err := secrets.GetSecret(ctx, tt.request)
+		if err != nil {
+			t.Fatalf("Failed to get secret %+v : %s", tt.request, err)
+		}
+
+		if string(got.Value) != tt.value {
+			t.Errorf("Wrong value for %+v : got %s, want %s", tt.request, got.Value, tt.value)
+		}
+
+		if got.VersionID != tt.version {
+			t.Errorf("Wrong version for %+v : got %s, want %s", tt.request, got.VersionID,
+				tt.version)
+		}
+	}
+
+	r := NewSecretResolver()
+	r.Register(SchemeSecretsManager, secrets)
+
+	urls := map[string]string{
+		"secretsmanager://payments":                                          "current",
+		"secretsmanager://payments?stage=AWSPENDING":                         "pending",
+		"secretsmanager://payments?version=" + testVersion1:                  "old",
+		"secretsmanager://payments?stage=AWSCURRENT&version=" + testVersion2: "current",
+	}
+
+	for value, want := range urls {
+		got, err := r.Resolve(ctx, value)
+		if err != nil {
+			t.Fatalf("Failed to resolve %s : %s", value, err)
+		}
+
+		if *got != want {
+			t.Errorf("Wrong value for %s : got %s, want %s", value, *got, want)
+		}
+	}
+
+	for _, value := range []string{
+		"secretsmanager://payments?stage=AWSPENDING&version=" + testVersion1,
+		"secretsmanager://payments?label=live",
+	} {
+		if _, err := r.Resolve(ctx, value); err == nil {
+			t.Errorf("Resolve should fail : %s", value)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tokenized/logger"
+)
+
+// DefaultCacheFetchTimeout limits how long a shared fetch by a CacheFetcher runs when no timeout is
+// set. It allows for the retries of a ParamStore or AWSSecretManager.
+const DefaultCacheFetchTimeout = 2 * time.Minute
+
+// CacheFetcher caches the values returned by another Fetcher for a time, so that several
+// components resolving the same secret only fetch it once. Concurrent calls for a name that isn't
+// cached share a single fetch.
+//
+// The shared fetch doesn't use the context of any one caller, so a caller that is cancelled stops
+// waiting without failing the others. It keeps the values of the first caller's context, such as
+// logging fields, and is limited by its own timeout instead.
+type CacheFetcher struct {
+	fetcher      Fetcher
+	ttl          time.Duration
+	timeout      time.Duration
+	staleOnError bool
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+	calls   map[string]*cacheCall
+}
+
+// cacheEntry is a cached value.
+type cacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// cacheCall is a fetch in progress that other calls for the same name wait for.
+type cacheCall struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// NewCacheFetcher returns a Fetcher that caches each value returned by fetcher for ttl. Errors are
+// not cached.
+func NewCacheFetcher(fetcher Fetcher, ttl time.Duration) *CacheFetcher {
+	return &CacheFetcher{
+		fetcher: fetcher,
+		ttl:     ttl,
+		timeout: DefaultCacheFetchTimeout,
+		now:     time.Now,
+		entries: make(map[string]*cacheEntry),
+		calls:   make(map[string]*cacheCall),
+	}
+}
+
+// SetStaleOnError makes Get return the expired value when refreshing it fails, instead of the
+// error, so that a short outage of the source doesn't fail callers. It should be set before the
+// CacheFetcher is used.
+func (c *CacheFetcher) SetStaleOnError(stale bool) {
+	c.staleOnError = stale
+}
+
+// SetTimeout sets the limit on how long a shared fetch runs. A timeout of zero or less only uses
+// the timeouts of the wrapped Fetcher. It should be set before the CacheFetcher is used.
+func (c *CacheFetcher) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// Get returns the cached value for name, or fetches it if it isn't cached or has expired.
+func (c *CacheFetcher) Get(ctx context.Context, name string) ([]byte, error) {
+	c.lock.Lock()
+	if entry, ok := c.entries[name]; ok && c.now().Before(entry.expires) {
+		c.lock.Unlock()
+		return copyBytes(entry.value), nil
+	}
+
+	call, inProgress := c.calls[name]
+	if !inProgress {
+		call = &cacheCall{done: make(chan struct{})}
+		c.calls[name] = call
+	}
+	c.lock.Unlock()
+
+	if !inProgress {
+		go c.fetch(detachedContext{ctx}, name, call)
+	}
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if call.err != nil {
+		return nil, call.err
+	}
+
+	return copyBytes(call.value), nil
+}
+
+// Invalidate removes the cached value for name so that the next Get fetches it again. A fetch
+// already in progress is not added to the cache.
+func (c *CacheFetcher) Invalidate(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.entries, name)
+	delete(c.calls, name)
+}
+
+// InvalidateAll removes all cached values.
+func (c *CacheFetcher) InvalidateAll() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries = make(map[string]*cacheEntry)
+	c.calls = make(map[string]*cacheCall)
+}
+
+// fetch gets the value from the wrapped Fetcher, stores it in the cache and releases the calls
+// waiting for it.
+func (c *CacheFetcher) fetch(ctx context.Context, name string, call *cacheCall) {
+	fetchCtx := ctx
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	value, err := c.fetcher.Get(fetchCtx, name)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer close(call.done)
+
+	current := c.calls[name] == call
+	if current {
+		delete(c.calls, name)
+	}
+
+	if err != nil {
+		entry, ok := c.entries[name]
+		if !ok || !c.staleOnError {
+			call.err = err
+			return
+		}
+
+		logger.Warn(ctx, "Failed to refresh %s, using value cached until %s : %s", name,
+			entry.expires, err)
+		call.value = entry.value
+		return
+	}
+
+	call.value = value
+	if current {
+		c.entries[name] = &cacheEntry{
+			value:   value,
+			expires: c.now().Add(c.ttl),
+		}
+	}
+}
+
+// detachedContext keeps the values of a context without its deadline or cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCountFetcher counts calls and can block until released.
+type testCountFetcher struct {
+	lock  sync.Mutex
+	calls int
+	value string
+	err   error
+
+	// release blocks calls until it is closed when it isn't nil.
+	release chan struct{}
+}
+
+func (f *testCountFetcher) Get(ctx context.Context, name string) ([]byte, error) {
+	f.lock.Lock()
+	f.calls++
+	release := f.release
+	value, err := f.value, f.err
+	f.lock.Unlock()
+
+	if release != nil {
+		<-release
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+func (f *testCountFetcher) set(value string, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.value = value
+	f.err = err
+}
+
+func (f *testCountFetcher) callCount() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.calls
+}
+
+// testClock is a time that tests move forward.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestCacheFetcher(t *testing.T) {
+	ctx := context.Background()
+	fetcher := &testCountFetcher{value: "first"}
+	clock := &testClock{now: time.Now()}
+
+	cache := NewCacheFetcher(fetcher, time.Minute)
+	cache.now = clock.Now
+
+	for i := 0; i < 3; i++ {
+		got, err := cache.Get(ctx, "secret")
+		if err != nil {
+			t.Fatalf("Failed to get : %s", err)
+		}
+		if string(got) != "first" {
+			t.Errorf("Wrong value : got %s, want %s", got, "first")
+		}
+	}
+
+	if fetcher.callCount() != 1 {
+		t.Errorf("Wrong calls : got %d, want %d", fetcher.callCount(), 1)
+	}
+
+	fetcher.set("second", nil)
+	clock.now = clock.now.Add(2 * time.Minute)
+
+	got, err := cache.Get(ctx, "secret")
+	if err != nil {
+		t.Fatalf("Failed to get : %s", err)
+	}
+	if string(got) != "second" {
+		t.Errorf("Wrong value after expiry : got %s, want %s", got, "second")
+	}
+
+	fetcher.set("third", nil)
+	cache.Invalidate("secret")
+
+	got, err = cache.Get(ctx, "secret")
+	if err != nil {
+		t.Fatalf("Failed to get : %s", err)
+	}
+	if string(got) != "third" {
+		t.Errorf("Wrong value after invalidate : got %s, want %s", got, "third")
+	}
+
+	if fetcher.callCount() != 3 {
+		t.Errorf("Wrong calls : got %d, want %d", fetcher.callCount(), 3)
+	}
+
+	fetcher.set("", errors.New("unavailable"))
+	cache.InvalidateAll()
+	if _, err := cache.Get(ctx, "secret"); err == nil {
+		t.Errorf("Get should fail when nothing is cached")
+	}
+	if _, err := cache.Get(ctx, "secret"); err == nil {
+		t.Errorf("Errors should not be cached")
+	}
+
+	if fetcher.callCount() != 5 {
+		t.Errorf("Wrong calls : got %d, want %d", fetcher.callCount(), 5)
+	}
+}
+
+func TestCacheFetcher_Concurrent(t *testing.T) {
+	fetcher := &testCountFetcher{value: "secret", release: make(chan struct{})}
+	cache := NewCacheFetcher(fetcher, time.Minute)
+
+	var wait sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+
+			got, err := cache.Get(context.Background(), "secret")
+			if err != nil {
+				t.Errorf("Failed to get : %s", err)
+				return
+			}
+			results[i] = string(got)
+		}(i)
+	}
+
+	// Give every goroutine time to wait for the fetch in progress.
+	time.Sleep(50 * time.Millisecond)
+	close(fetcher.release)
+	wait.Wait()
+
+	if fetcher.callCount() != 1 {
+		t.Errorf("Wrong calls : got %d, want %d", fetcher.callCount(), 1)
+	}
+
+	for i, result := range results {
+		if result != "secret" {
+			t.Errorf("Wrong value %d : got %s, want %s", i, result, "secret")
+		}
+	}
+}
+
+func TestCacheFetcher_StaleOnError(t *testing.T) {
+	ctx := context.Background()
+	fetcher := &testCountFetcher{value: "cached"}
+	clock := &testClock{now: time.Now()}
+
+	cache := NewCacheFetcher(fetcher, time.Minute)
+	cache.now = clock.Now
+
+	if _, err := cache.Get(ctx, "secret"); err != nil {
+		t.Fatalf("Failed to get : %s", err)
+	}
+
+	fetcher.set("", errors.New("unavailable"))
+	clock.now = clock.now.Add(2 * time.Minute)
+
+	if _, err := cache.Get(ctx, "secret"); err == nil {
+		t.Errorf("Get should fail without stale on error")
+	}
+
+	cache.SetStaleOnError(true)
+	got, err := cache.Get(ctx, "secret")
+	if err != nil {
+		t.Fatalf("Failed to get stale value : %s", err)
+	}
+	if string(got) != "cached" {
+		t.Errorf("Wrong stale value : got %s, want %s", got, "cached")
+	}
+
+	fetcher.set("refreshed", nil)
+	got, err = cache.Get(ctx, "secret")
+	if err != nil {
+		t.Fatalf("Failed to get : %s", err)
+	}
+	if string(got) != "refreshed" {
+		t.Errorf("Wrong value after refresh : got %s, want %s", got, "refreshed")
+	}
+}
+
+func TestCacheFetcher_CallerCancelled(t *testing.T) {
+	fetcher := &testCountFetcher{value: "secret", release: make(chan struct{})}
+	cache := NewCacheFetcher(fetcher, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(ctx, "secret")
+		first <- err
+	}()
+
+	second := make(chan string, 1)
+	go func() {
+		got, err := cache.Get(context.Background(), "secret")
+		if err != nil {
+			t.Errorf("Second caller should not be failed by the first : %s", err)
+		}
+		second <- string(got)
+	}()
+
+	// Give both goroutines time to wait for the fetch in progress.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-first; err != context.Canceled {
+		t.Errorf("Wrong first caller error : got %v, want %v", err, context.Canceled)
+	}
+
+	close(fetcher.release)
+	if got := <-second; got != "secret" {
+		t.Errorf("Wrong value : got %s, want %s", got, "secret")
+	}
+
+	if fetcher.callCount() != 1 {
+		t.Errorf("Wrong calls : got %d, want %d", fetcher.callCount(), 1)
+	}
+
+	// The value fetched for the cancelled caller is cached.
+	if _, err := cache.Get(context.Background(), "secret"); err != nil {
+		t.Fatalf("Failed to get : %s", err)
+	}
+	if fetcher.callCount() != 1 {
+		t.Errorf("Wrong calls after cache hit : got %d, want %d", fetcher.callCount(), 1)
+	}
+}
+
+func TestCacheFetcher_Timeout(t *testing.T) {
+	fetcher := FetcherFunc(func(ctx context.Context, name string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	cache := NewCacheFetcher(fetcher, time.Minute)
+	cache.SetTimeout(10 * time.Millisecond)
+
+	if _, err := cache.Get(context.Background(), "secret"); err != context.DeadlineExceeded {
+		t.Errorf("Wrong error : got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -2,17 +2,10 @@ package config
 
 import (
 	"context"
-	"encoding/json"
-	"io/ioutil"
-	"os"
 
 	"github.com/tokenized/logger"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/kelseyhightower/envconfig"
-	"github.com/pkg/errors"
 )
 
 const (
@@ -28,67 +21,59 @@ const (
 	// config from the AWS ParamStore.
 	EnvParamName = "PARAM_NAME"
 
+	// EnvParamPath is the environment variable to use when loading config
+	// from all of the parameters under an AWS ParamStore path.
+	EnvParamPath = "PARAM_PATH"
+
 	// EnvConfigFile is the the environment variable to use when loading
-	// JSON config from a file.
+	// config from a file.
 	EnvConfigFile = "CONFIG_FILE"
 )
 
 // LoadConfig is the common way to load config.
 //
-// It will attempt to load from param store, then config file, falling back
-// to the environment.
+// It will load struct defaults, then a config file, then the param store, and
+// finally apply any environment variables that are set. Each layer is merged
+// into the struct, so later layers override values from earlier layers.
+//
+// To load from the ParamStore, the PARAM_NAME env var should be set with the
+// name of the item to load, or PARAM_PATH with a path such as "/svc/prod/" to
+// load each parameter under it into a field. See LoadParamStorePath.
 //
-// To load from the ParamStore, the PARAM_STORE env var should be set with the
-// name of the item to load.
+// To load from a config file, the CONFIG_FILE env var should have the name of
+// the file to load. The format is chosen by the file extension.
 //
-// To load from a JSON config file, the CONFIG_FILE env var should have the name
-// of the file to load.
+// The loaded struct is checked with its `validate` tags, see Validate. Rules
+// that this package doesn't know, such as `validate:"gte=1"` from other
+// validation packages, are skipped rather than failing the load.
 //
-// Fallback option is to load config from environment variables.
-func LoadConfig(ctx context.Context, cfg interface{}) error {
-	// check the PARAM_NAME env var
-	paramName := os.Getenv(EnvParamName)
-
-	if len(paramName) > 0 {
-		// we have a parameter name, try to load it
-		logger.Info(ctx, "Loading config from param store : %s", paramName)
-		return LoadParamStore(paramName, cfg)
-	}
-
-	// check the CONFIG_FILE env var
-	filename := os.Getenv(EnvConfigFile)
-
-	if len(filename) > 0 {
-		logger.Info(ctx, "Loading config from file : %s", filename)
-		return LoadFromFile(filename, cfg)
-	}
-
-	logger.Info(ctx, "Loading config from environment")
-	return LoadEnvironment(cfg)
+// Use LoadConfigWithOptions to change the order of the layers. Options such as
+// WithPrefix can also be given here.
+func LoadConfig(ctx context.Context, cfg interface{}, opts ...Option) error {
+	return LoadConfigWithOptions(ctx, cfg, opts...)
 }
 
-// LoadFromFile loads a JSON config from a file.
-func LoadFromFile(filename string, cfg interface{}) error {
-	// Load default values from environment definitions.
-	if err := LoadEnvironment(cfg); err != nil {
-		return errors.Wrap(err, "load environment defaults")
-	}
-
-	b, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return errors.Wrap(err, "read file")
-	}
-
-	if err := json.Unmarshal(b, cfg); err != nil {
-		return errors.Wrap(err, "json")
-	}
+// LoadFromFile loads a config from a file.
+//
+// Files are JSON unless the extension or the WithFormat option selects another
+// format. YAML and TOML files use the `json` tags for field names. A dotenv
+// file sets fields the same way as environment variables.
+//
+// Values set in the file override values from the environment unless the
+// WithEnvironmentOverrides option is given.
+func LoadFromFile(filename string, cfg interface{}, opts ...Option) error {
+	o := newOptions(opts)
 
-	return nil
+	return o.loadSingleSource(cfg, func(cfg interface{}) error {
+		return o.unmarshalFile(context.Background(), filename, cfg)
+	})
 }
 
 // LoadEnvironment attempts to hydrate a struct with environment variables.
-func LoadEnvironment(cfg interface{}) error {
-	return envconfig.Process("", cfg)
+//
+// The WithPrefix option adds a prefix to the environment variable names.
+func LoadEnvironment(cfg interface{}, opts ...Option) error {
+	return envconfig.Process(newOptions(opts).prefix, cfg)
 }
 
 // LoadParamStore returns unmarshals the an AWS ParamStore value into a
@@ -101,52 +86,25 @@ func LoadEnvironment(cfg interface{}) error {
 // JSON config that it loads, which is expected to fit the struct it is being
 // marshalled into.
 //
+// Values set in the ParamStore item override values from the environment
+// unless the WithEnvironmentOverrides option is given.
+//
 // It is intended to eventually replace the usage of ParamStore, which
 // requires a specific type.
-func LoadParamStore(keyName string, cfg interface{}) error {
-	// Load default values from environment definitions.
-	if err := LoadEnvironment(cfg); err != nil {
-		return errors.Wrap(err, "load environment defaults")
-	}
-
-	b, err := fetchFromParamStore(keyName)
-	if err != nil {
-		return errors.Wrap(err, "fetch param store")
-	}
-
-	// Unmarshal param value
-	return json.Unmarshal(b, cfg)
+func LoadParamStore(keyName string, cfg interface{}, opts ...Option) error {
+	return LoadParamStoreWithContext(context.Background(), keyName, cfg, opts...)
 }
 
-// fetchFromParamStore loads the data from the AWS ParamStore.
-func fetchFromParamStore(keyName string) ([]byte, error) {
-	// Locate param value
-	sess, err := session.NewSessionWithOptions(session.Options{
-		Config:            aws.Config{Region: aws.String(os.Getenv("AWS_REGION"))},
-		SharedConfigState: session.SharedConfigEnable,
-	})
-
-	if err != nil {
-		return nil, errors.Wrap(err, "new session")
-	}
-
-	ssmsvc := ssm.New(sess, aws.NewConfig().WithRegion(os.Getenv("AWS_REGION")))
+// LoadParamStoreWithContext is LoadParamStore with a context that can cancel the request to the
+// ParamStore or limit how long it waits.
+func LoadParamStoreWithContext(ctx context.Context, keyName string, cfg interface{},
+	opts ...Option) error {
 
-	withDecryption := true
+	o := newOptions(opts)
 
-	param, err := ssmsvc.GetParameter(&ssm.GetParameterInput{
-		Name:           &keyName,
-		WithDecryption: &withDecryption,
+	return o.loadSingleSource(cfg, func(cfg interface{}) error {
+		return o.unmarshalParamStore(ctx, keyName, cfg)
 	})
-
-	if err != nil {
-		return nil, errors.Wrap(err, "get parameters")
-	}
-
-	// Unmarshal param value
-	b := []byte(*param.Parameter.Value)
-
-	return b, nil
 }
 
 // DumpSafe logs a "safe" version of the config, with sensitive values masked.
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Defaulter is implemented by config structs that set their own default values, for example
+// values that are calculated or awkward to write in a `default` tag. SetDefaults is called on the
+// root config and every nested struct after the `default` tags of the struct are applied and
+// before any source is loaded.
+//
+// Structs within slices and maps only exist once a source is loaded, so their `default` tags and
+// SetDefaults are applied to each new item just before the source's values for that item are
+// decoded into it. Values from the source, including zero values, are kept.
+type Defaulter interface {
+	SetDefaults()
+}
+
+// SetDefaults sets the fields of cfg from their `default` tags and calls SetDefaults on any
+// structs that implement Defaulter. Unlike LoadEnvironment it includes fields that envconfig
+// ignores, so JSON only fields can have defaults too.
+//
+// Tag values use the same format as environment variables, except for structs, and slices and
+// maps of structs, which use JSON, for example `default:"[{\"host\": \"localhost\"}]"`.
+func SetDefaults(cfg interface{}) error {
+	return newOptions(nil).applyDefaults(cfg)
+}
+
+// applyDefaults sets every field that has a `default` tag to its default value.
+func (o *options) applyDefaults(cfg interface{}) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("defaults require a pointer to a struct")
+	}
+
+	o.defaultsApplied = true
+	return o.setStructDefaults(v.Elem(), "")
+}
+
+// setStructDefaults applies the defaults of the nested structs of a struct, then its `default`
+// tags, and then calls SetDefaults. A `default` tag on a struct field is decoded over the defaults
+// of the nested struct.
+func (o *options) setStructDefaults(s reflect.Value, parent string) error {
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		f := s.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+
+		path := joinPath(parent, ft.Name)
+		if ft.Anonymous {
+			path = parent
+		}
+
+		nested := f
+		for nested.Kind() == reflect.Ptr {
+			if nested.IsNil() {
+				if nested.Type().Elem().Kind() != reflect.Struct {
+					break
+				}
+				nested.Set(reflect.New(nested.Type().Elem()))
+			}
+			nested = nested.Elem()
+		}
+
+		if nested.Kind() == reflect.Struct && !isJSONLeaf(nested.Type()) && !hasDecoder(nested) {
+			if err := o.setStructDefaults(nested, path); err != nil {
+				return err
+			}
+		}
+
+		if def := ft.Tag.Get("default"); len(def) > 0 {
+			if err := o.decodeDefault(def, f, path); err != nil {
+				return errors.Wrapf(err, "default %s", path)
+			}
+			o.record(path, FieldSource{Source: SourceDefaults})
+		}
+	}
+
+	if defaulter, ok := s.Addr().Interface().(Defaulter); ok {
+		defaulter.SetDefaults()
+	}
+
+	return nil
+}
+
+// setItemDefaults sets the defaults of a new slice or map item if it is a struct.
+func (o *options) setItemDefaults(item reflect.Value, path string) error {
+	s := item
+	for s.Kind() == reflect.Ptr {
+		if s.IsNil() {
+			if s.Type().Elem().Kind() != reflect.Struct {
+				return nil
+			}
+			s.Set(reflect.New(s.Type().Elem()))
+		}
+		s = s.Elem()
+	}
+
+	if s.Kind() != reflect.Struct || isJSONLeaf(s.Type()) || hasDecoder(s) {
+		return nil
+	}
+
+	return o.setStructDefaults(s, path)
+}
+
+// setDecodedDefaults finds the slice and map items in v that were decoded from value, which is
+// the JSON that was unmarshalled into v, and decodes each struct item again over its defaults.
+// Fields set by the item's JSON are recorded with source.
+func (o *options) setDecodedDefaults(v reflect.Value, value interface{}, path string,
+	source FieldSource) error {
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok || isJSONLeaf(v.Type()) {
+			return nil
+		}
+
+		fields := jsonFields(v.Type())
+		for key, fieldValue := range object {
+			field, found := findJSONField(fields, key)
+			if !found {
+				continue
+			}
+
+			if err := o.setDecodedDefaults(v.FieldByName(field.Path), fieldValue,
+				joinPath(path, field.Path), source); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+
+		for i := 0; i < len(items) && i < v.Len(); i++ {
+			if err := o.decodeItem(v.Index(i), items[i], fmt.Sprintf("%s[%d]", path, i),
+				source); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		for _, key := range v.MapKeys() {
+			itemValue, ok := object[fmt.Sprintf("%v", key)]
+			if !ok {
+				continue
+			}
+
+			// Map values aren't addressable, so update a copy and store it back.
+			item := reflect.New(v.Type().Elem()).Elem()
+			item.Set(v.MapIndex(key))
+
+			if err := o.decodeItem(item, itemValue, fmt.Sprintf("%s[%v]", path, key),
+				source); err != nil {
+				return err
+			}
+
+			v.SetMapIndex(key, item)
+		}
+	}
+
+	return nil
+}
+
+// decodeItem replaces a struct item with its defaults and then decodes the item's JSON over them,
+// the same way the root config is loaded.
+func (o *options) decodeItem(item reflect.Value, value interface{}, path string,
+	source FieldSource) error {
+
+	s := item
+	for s.Kind() == reflect.Ptr {
+		if s.IsNil() {
+			return nil
+		}
+		s = s.Elem()
+	}
+
+	object, isObject := value.(map[string]interface{})
+	if isObject && s.Kind() == reflect.Struct && !isJSONLeaf(s.Type()) && !hasDecoder(s) {
+		fresh := reflect.New(s.Type())
+		if err := o.setStructDefaults(fresh.Elem(), path); err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(object)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(b, fresh.Interface()); err != nil {
+			return err
+		}
+
+		paths, err := jsonPaths(b, fresh.Interface())
+		if err != nil {
+			return err
+		}
+
+		for _, fieldPath := range paths {
+			o.record(joinPath(path, fieldPath), source)
+		}
+
+		s.Set(fresh.Elem())
+	}
+
+	return o.setDecodedDefaults(s, value, path, source)
+}
+
+// setJSONDefaults applies the defaults of structs in slices and maps that were just unmarshalled
+// from the JSON in b into v, when the defaults layer has been loaded.
+func (o *options) setJSONDefaults(b []byte, v interface{}, path string, source FieldSource) error {
+	if !o.defaultsApplied {
+		return nil
+	}
+
+	// Numbers are kept as they are written so that items are decoded again without change.
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	return o.setDecodedDefaults(reflect.ValueOf(v), value, path, source)
+}
+
+// decodeDefault sets a field from the text of a `default` tag. Values that can't be represented as
+// environment variables are decoded as JSON.
+func (o *options) decodeDefault(value string, field reflect.Value, path string) error {
+	if hasDecoder(field) || !needsJSONDefault(field.Type()) {
+		return decodeText(value, field)
+	}
+
+	b := []byte(value)
+	if err := json.Unmarshal(b, field.Addr().Interface()); err != nil {
+		return err
+	}
+
+	return o.setJSONDefaults(b, field.Addr().Interface(), path, FieldSource{Source: SourceDefaults})
+}
+
+// needsJSONDefault returns true for types that envconfig can't decode from text.
+func needsJSONDefault(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	pt := reflect.PtrTo(t)
+	if pt.Implements(textUnmarshalerType) {
+		return false
+	}
+
+	if pt.Implements(jsonUnmarshalerType) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Slice, reflect.Array:
+		return needsJSONDefault(t.Elem())
+	case reflect.Map:
+		return needsJSONDefault(t.Elem())
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testDefaultsUpstream struct {
+	Host    string   `json:"host"`
+	Weight  int      `json:"weight" default:"1"`
+	Timeout Duration `json:"timeout" default:"3s"`
+}
+
+// SetDefaults sets the host without checking it is empty, so loaded values must be decoded after it
+// is called.
+func (u *testDefaultsUpstream) SetDefaults() {
+	u.Host = "localhost"
+}
+
+type testDefaultsConfig struct {
+	Name      string                          `json:"name" default:"service"`
+	Interval  Duration                        `json:"interval" default:"30s" ignored:"true"`
+	Wait      time.Duration                   `json:"wait" default:"1m"`
+	Hosts     []string                        `json:"hosts" default:"a,b"`
+	Upstreams []testDefaultsUpstream          `json:"upstreams" ignored:"true"`
+	Named     map[string]testDefaultsUpstream `json:"named" ignored:"true"`
+	Fallback  testDefaultsUpstream            `json:"fallback" ignored:"true" default:"{\"host\": \"fallback\"}"`
+	Computed  string                          `json:"computed" ignored:"true"`
+}
+
+func (c *testDefaultsConfig) SetDefaults() {
+	c.Computed = c.Name + "-computed"
+}
+
+func TestSetDefaults(t *testing.T) {
+	cfg := &testDefaultsConfig{}
+	if err := SetDefaults(cfg); err != nil {
+		t.Fatalf("Failed to set defaults : %s", err)
+	}
+
+	if cfg.Name != "service" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "service")
+	}
+
+	if cfg.Interval.Duration != 30*time.Second {
+		t.Errorf("Wrong interval : got %s, want %s", cfg.Interval, 30*time.Second)
+	}
+
+	if cfg.Wait != time.Minute {
+		t.Errorf("Wrong wait : got %s, want %s", cfg.Wait, time.Minute)
+	}
+
+	if len(cfg.Hosts) != 2 || cfg.Hosts[1] != "b" {
+		t.Errorf("Wrong hosts : got %v, want %v", cfg.Hosts, []string{"a", "b"})
+	}
+
+	// The defaults of the nested struct are applied, then the JSON default over them.
+	if cfg.Fallback.Host != "fallback" || cfg.Fallback.Weight != 1 {
+		t.Errorf("Wrong fallback : got %+v", cfg.Fallback)
+	}
+
+	if cfg.Computed != "service-computed" {
+		t.Errorf("Wrong computed : got %s, want %s", cfg.Computed, "service-computed")
+	}
+}
+
+func TestLoadConfigWithOptions_ElementDefaults(t *testing.T) {
+	filename := writeTestFile(t, "config.yaml", `
+upstreams:
+  - host: a
+  - host: b
+    weight: 5
+  - weight: 0
+named:
+  primary:
+    host: c
+`)
+
+	cfg := &testDefaultsConfig{}
+	if err := LoadConfigWithOptions(context.Background(), cfg, WithConfigFile(filename),
+		WithParamName("")); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if len(cfg.Upstreams) != 3 {
+		t.Fatalf("Wrong upstream count : got %d, want %d", len(cfg.Upstreams), 3)
+	}
+
+	if cfg.Upstreams[0].Weight != 1 || cfg.Upstreams[0].Timeout.Duration != 3*time.Second {
+		t.Errorf("Wrong upstream 0 : got %+v", cfg.Upstreams[0])
+	}
+
+	// Loaded values are kept.
+	if cfg.Upstreams[1].Weight != 5 {
+		t.Errorf("Wrong upstream 1 weight : got %d, want %d", cfg.Upstreams[1].Weight, 5)
+	}
+
+	// An explicit zero is kept, and fields not in the file have their defaults.
+	if cfg.Upstreams[2].Weight != 0 || cfg.Upstreams[2].Host != "localhost" ||
+		cfg.Upstreams[2].Timeout.Duration != 3*time.Second {
+		t.Errorf("Wrong upstream 2 : got %+v", cfg.Upstreams[2])
+	}
+
+	if cfg.Named["primary"].Weight != 1 || cfg.Named["primary"].Host != "c" {
+		t.Errorf("Wrong named : got %+v", cfg.Named["primary"])
+	}
+
+	if cfg.Interval.Duration != 30*time.Second {
+		t.Errorf("Wrong interval : got %s, want %s", cfg.Interval, 30*time.Second)
+	}
+}
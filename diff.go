@@ -0,0 +1,436 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ChangeType describes how a field differs between two configs.
+type ChangeType string
+
+const (
+	// ChangeAdded means the field is empty in the old config and set in the new config.
+	ChangeAdded = ChangeType("added")
+
+	// ChangeRemoved means the field is set in the old config and empty in the new config.
+	ChangeRemoved = ChangeType("removed")
+
+	// ChangeChanged means the field is set to different values in the two configs. Masked fields
+	// are always reported as changed.
+	ChangeChanged = ChangeType("changed")
+)
+
+// FieldChange is a difference in one field between two configs.
+type FieldChange struct {
+	// Path is the Go field path, the same as in Provenance and FieldError, for example "DB.Host",
+	// "Servers[1].Name" or "Labels[region]".
+	Path string `json:"path"`
+
+	Type ChangeType `json:"type"`
+
+	// Old and New are the display values of the field. They are empty for masked fields.
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+
+	// Masked is true for fields tagged `masked:"true"`.
+	Masked bool `json:"masked,omitempty"`
+
+	// OldFingerprint and NewFingerprint are short hashes of masked values so that a change can be
+	// confirmed without showing the values.
+	OldFingerprint string `json:"old_fingerprint,omitempty"`
+	NewFingerprint string `json:"new_fingerprint,omitempty"`
+}
+
+// ConfigDiff is the list of differences between two configs, sorted by path.
+type ConfigDiff []FieldChange
+
+// Diff compares two configs of the same type and returns the fields that differ. Fields are
+// walked the same way as MarshalJSONMasked, so values tagged `masked:"true"` are never included.
+func Diff(old, new interface{}) (ConfigDiff, error) {
+	oldValue := reflect.Indirect(reflect.ValueOf(old))
+	newValue := reflect.Indirect(reflect.ValueOf(new))
+
+	if oldValue.Type() != newValue.Type() {
+		return nil, errors.Errorf("can't compare %s to %s", oldValue.Type(), newValue.Type())
+	}
+
+	if oldValue.Kind() != reflect.Struct {
+		return nil, errors.Errorf("can't compare non struct %s", oldValue.Type())
+	}
+
+	var result ConfigDiff
+	if err := diffStruct(oldValue, newValue, "", &result); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result, nil
+}
+
+func diffStruct(old, new reflect.Value, parent string, result *ConfigDiff) error {
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		oldField := old.Field(i)
+		newField := new.Field(i)
+
+		if !oldField.CanInterface() {
+			continue // not exported
+		}
+
+		if strings.Split(field.Tag.Get("json"), ",")[0] == "-" {
+			continue
+		}
+
+		path := joinPath(parent, field.Name)
+		if field.Anonymous {
+			path = parent
+		}
+
+		if field.Tag.Get("masked") == "true" {
+			if err := diffMasked(oldField, newField, path, result); err != nil {
+				return errors.Wrapf(err, "masked field: %s", field.Name)
+			}
+			continue
+		}
+
+		if err := diffValue(oldField, newField, path, result); err != nil {
+			return errors.Wrapf(err, "field: %s", field.Name)
+		}
+	}
+
+	return nil
+}
+
+func diffValue(old, new reflect.Value, path string, result *ConfigDiff) error {
+	if old.Kind() == reflect.Ptr {
+		if old.IsNil() && new.IsNil() {
+			return nil
+		}
+
+		if !old.IsNil() && !new.IsNil() {
+			return diffValue(old.Elem(), new.Elem(), path, result)
+		}
+
+		// Compare a struct that was added or removed with an empty struct so that its masked
+		// fields are still only reported by fingerprint.
+		if old.Type().Elem().Kind() == reflect.Struct {
+			return diffValue(elemOrZero(old), elemOrZero(new), path, result)
+		}
+	}
+
+	iface := old.Interface()
+	_, isMarshaler := iface.(json.Marshaler)
+	_, isStringer := iface.(fmt.Stringer)
+
+	if !isMarshaler && !isStringer {
+		switch old.Kind() {
+		case reflect.Struct:
+			return diffStruct(old, new, path, result)
+
+		case reflect.Map:
+			return diffMap(old, new, path, result)
+
+		case reflect.Slice, reflect.Array:
+			if old.Type().Elem().Kind() != reflect.Uint8 {
+				return diffSlice(old, new, path, result)
+			}
+		}
+	}
+
+	oldString, err := displayValue(old)
+	if err != nil {
+		return err
+	}
+
+	newString, err := displayValue(new)
+	if err != nil {
+		return err
+	}
+
+	if oldString == newString {
+		return nil
+	}
+
+	change := FieldChange{
+		Path: path,
+		Type: ChangeChanged,
+		Old:  oldString,
+		New:  newString,
+	}
+
+	if isEmptyValue(old) {
+		change.Type = ChangeAdded
+	} else if isEmptyValue(new) {
+		change.Type = ChangeRemoved
+	}
+
+	*result = append(*result, change)
+	return nil
+}
+
+// elemOrZero returns the value a pointer points to, or the zero value of that type for a nil
+// pointer.
+func elemOrZero(v reflect.Value) reflect.Value {
+	if v.IsNil() {
+		return reflect.Zero(v.Type().Elem())
+	}
+	return v.Elem()
+}
+
+// diffSlice compares each item of two slices or arrays, using the index in the path, for example
+// "Servers[1]". Items that
+// only exist in one of them are compared with an empty item when they are structs, so that masked
+// fields in them are only reported by fingerprint.
+func diffSlice(old, new reflect.Value, path string, result *ConfigDiff) error {
+	count := old.Len()
+	if new.Len() > count {
+		count = new.Len()
+	}
+
+	for i := 0; i < count; i++ {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+
+		if i < old.Len() && i < new.Len() {
+			if err := diffValue(old.Index(i), new.Index(i), itemPath, result); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var item reflect.Value
+		if i < old.Len() {
+			item = old.Index(i)
+		} else {
+			item = new.Index(i)
+		}
+
+		if item.Kind() == reflect.Struct ||
+			(item.Kind() == reflect.Ptr && item.Type().Elem().Kind() == reflect.Struct) {
+
+			zero := reflect.Zero(item.Type())
+			if i < old.Len() {
+				if err := diffValue(item, zero, itemPath, result); err != nil {
+					return err
+				}
+			} else if err := diffValue(zero, item, itemPath, result); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, err := displayValue(item)
+		if err != nil {
+			return err
+		}
+
+		if i < old.Len() {
+			*result = append(*result, FieldChange{Path: itemPath, Type: ChangeRemoved, Old: s})
+		} else {
+			*result = append(*result, FieldChange{Path: itemPath, Type: ChangeAdded, New: s})
+		}
+	}
+
+	return nil
+}
+
+// diffMap compares each key of two maps so that added and removed keys are reported separately.
+// The key is used in the path, for example "Labels[region]".
+func diffMap(old, new reflect.Value, path string, result *ConfigDiff) error {
+	keys := make(map[string]reflect.Value)
+	for _, key := range old.MapKeys() {
+		keys[fmt.Sprintf("%v", key)] = key
+	}
+	for _, key := range new.MapKeys() {
+		keys[fmt.Sprintf("%v", key)] = key
+	}
+
+	for name, key := range keys {
+		oldItem := old.MapIndex(key)
+		newItem := new.MapIndex(key)
+		itemPath := fmt.Sprintf("%s[%s]", path, name)
+
+		switch {
+		case !oldItem.IsValid():
+			s, err := displayValue(newItem)
+			if err != nil {
+				return err
+			}
+			*result = append(*result, FieldChange{Path: itemPath, Type: ChangeAdded, New: s})
+
+		case !newItem.IsValid():
+			s, err := displayValue(oldItem)
+			if err != nil {
+				return err
+			}
+			*result = append(*result, FieldChange{Path: itemPath, Type: ChangeRemoved, Old: s})
+
+		default:
+			if err := diffValue(oldItem, newItem, itemPath, result); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// diffMasked reports a change to a masked field with fingerprints instead of values.
+func diffMasked(old, new reflect.Value, path string, result *ConfigDiff) error {
+	oldString, err := displayValue(old)
+	if err != nil {
+		return err
+	}
+
+	newString, err := displayValue(new)
+	if err != nil {
+		return err
+	}
+
+	if oldString == newString {
+		return nil
+	}
+
+	*result = append(*result, FieldChange{
+		Path:           path,
+		Type:           ChangeChanged,
+		Masked:         true,
+		OldFingerprint: fingerprint(oldString),
+		NewFingerprint: fingerprint(newString),
+	})
+
+	return nil
+}
+
+// displayValue returns the text of a value the same way MarshalJSONMasked outputs unmasked values.
+// Structs are output with MarshalJSONMasked, including those inside pointers, slices, arrays and
+// maps, so masked values are never included.
+func displayValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", nil
+	}
+
+	iface := v.Interface()
+	if marshaler, ok := iface.(json.Marshaler); ok {
+		b, err := marshaler.MarshalJSON()
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	if stringer, ok := iface.(fmt.Stringer); ok {
+		return stringer.String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "", nil
+		}
+		return displayValue(v.Elem())
+
+	case reflect.Struct:
+		b, err := MarshalJSONMasked(iface)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s, err := displayValue(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return "[" + strings.Join(items, " ") + "]", nil
+
+	case reflect.Map:
+		items := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			s, err := displayValue(v.MapIndex(key))
+			if err != nil {
+				return "", err
+			}
+			items = append(items, fmt.Sprintf("%v:%s", key, s))
+		}
+		sort.Strings(items)
+		return "map[" + strings.Join(items, " ") + "]", nil
+	}
+
+	return fmt.Sprintf("%v", iface), nil
+}
+
+// isEmptyValue returns true for zero values and empty slices and maps.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+
+	return v.IsZero()
+}
+
+// fingerprint returns a short hash of a value that can be compared without revealing it.
+func fingerprint(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:4])
+}
+
+// String returns a human readable report with one line per change.
+func (d ConfigDiff) String() string {
+	if len(d) == 0 {
+		return "no changes"
+	}
+
+	lines := make([]string, 0, len(d))
+	for _, change := range d {
+		lines = append(lines, change.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (c FieldChange) String() string {
+	if c.Masked {
+		return fmt.Sprintf("%s %s (masked %s -> %s)", c.Type, c.Path, fingerprintText(c.OldFingerprint),
+			fingerprintText(c.NewFingerprint))
+	}
+
+	switch c.Type {
+	case ChangeAdded:
+		return fmt.Sprintf("added %s = %s", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("removed %s (was %s)", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("changed %s : %s -> %s", c.Path, c.Old, c.New)
+	}
+}
+
+func fingerprintText(f string) string {
+	if len(f) == 0 {
+		return "empty"
+	}
+	return f
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDiffDB struct {
+	Host     string `json:"host"`
+	Password string `json:"password" masked:"true"`
+}
+
+type testDiffConfig struct {
+	Name    string            `json:"name"`
+	Timeout Duration          `json:"Timeout"`
+	APIKey  string            `json:"APIKey" masked:"true"`
+	Debug   bool              `json:"Debug"`
+	Owner   string            `json:"Owner"`
+	DB      testDiffDB        `json:"db"`
+	Labels  map[string]string `json:"labels"`
+}
+
+func TestDiff(t *testing.T) {
+	old := &testDiffConfig{
+		Name:    "staging",
+		Timeout: NewDuration(5 * time.Second),
+		APIKey:  "key1",
+		Owner:   "payments",
+		DB:      testDiffDB{Host: "db.staging", Password: "pass1"},
+		Labels:  map[string]string{"team": "payments", "tier": "2"},
+	}
+
+	new := &testDiffConfig{
+		Name:    "prod",
+		Timeout: NewDuration(10 * time.Second),
+		APIKey:  "key2",
+		Debug:   true,
+		DB:      testDiffDB{Host: "db.staging", Password: "pass2"},
+		Labels:  map[string]string{"team": "payments", "region": "eu"},
+	}
+
+	diff, err := Diff(old, new)
+	if err != nil {
+		t.Fatalf("Failed to diff : %s", err)
+	}
+
+	t.Logf("Diff :\n%s", diff)
+
+	var tests = []struct {
+		path       string
+		changeType ChangeType
+		old, new   string
+		masked     bool
+	}{
+		{"APIKey", ChangeChanged, "", "", true},
+		{"DB.Password", ChangeChanged, "", "", true},
+		{"Debug", ChangeAdded, "false", "true", false},
+		{"Labels[region]", ChangeAdded, "", "eu", false},
+		{"Labels[tier]", ChangeRemoved, "2", "", false},
+		{"Name", ChangeChanged, "staging", "prod", false},
+		{"Owner", ChangeRemoved, "payments", "", false},
+		{"Timeout", ChangeChanged, `"5s"`, `"10s"`, false},
+	}
+
+	if len(diff) != len(tests) {
+		t.Fatalf("Wrong change count : got %d, want %d", len(diff), len(tests))
+	}
+
+	for i, test := range tests {
+		change := diff[i]
+		if change.Path != test.path || change.Type != test.changeType ||
+			change.Old != test.old || change.New != test.new || change.Masked != test.masked {
+			t.Errorf("Wrong change (%d) : got %+v, want %+v", i, change, test)
+		}
+
+		if test.masked && (len(change.OldFingerprint) == 0 ||
+			change.OldFingerprint == change.NewFingerprint) {
+			t.Errorf("Wrong fingerprints (%d) : %+v", i, change)
+		}
+	}
+
+	// Masked values must never be output.
+	js, _ := json.Marshal(diff)
+	for _, secret := range []string{"key1", "key2", "pass1", "pass2"} {
+		if strings.Contains(diff.String(), secret) || strings.Contains(string(js), secret) {
+			t.Errorf("Diff contains masked value %s", secret)
+		}
+	}
+
+	same, err := Diff(old, old)
+	if err != nil {
+		t.Fatalf("Failed to diff same : %s", err)
+	}
+
+	if len(same) != 0 {
+		t.Errorf("Same config should have no changes : %s", same)
+	}
+
+	if _, err := Diff(old, &testLayeredConfig{}); err == nil {
+		t.Errorf("Diff of different types should fail")
+	}
+}
+
+type testDiffServer struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret" masked:"true"`
+}
+
+type testDiffNestedConfig struct {
+	Creds   *testDiffDB       `json:"creds"`
+	Servers []testDiffServer  `json:"servers"`
+	Backups []*testDiffServer `json:"backups"`
+	Hosts   []string          `json:"hosts"`
+}
+
+func TestDiff_Nested(t *testing.T) {
+	old := &testDiffNestedConfig{
+		Servers: []testDiffServer{{Name: "a", Secret: "secret1"}},
+		Backups: []*testDiffServer{{Name: "b", Secret: "secret2"}},
+		Hosts:   []string{"h1", "h2"},
+	}
+
+	new := &testDiffNestedConfig{
+		Creds: &testDiffDB{Host: "u", Password: "hunter2"},
+		Servers: []testDiffServer{
+			{Name: "a", Secret: "secret3"},
+			{Name: "c", Secret: "s3cret"},
+		},
+		Hosts: []string{"h1"},
+	}
+
+	diff, err := Diff(old, new)
+	if err != nil {
+		t.Fatalf("Failed to diff : %s", err)
+	}
+
+	t.Logf("Diff :\n%s", diff)
+
+	var tests = []struct {
+		path       string
+		changeType ChangeType
+		old, new   string
+		masked     bool
+	}{
+		{"Backups[0].Name", ChangeRemoved, "b", "", false},
+		{"Backups[0].Secret", ChangeChanged, "", "", true},
+		{"Creds.Host", ChangeAdded, "", "u", false},
+		{"Creds.Password", ChangeChanged, "", "", true},
+		{"Hosts[1]", ChangeRemoved, "h2", "", false},
+		{"Servers[0].Secret", ChangeChanged, "", "", true},
+		{"Servers[1].Name", ChangeAdded, "", "c", false},
+		{"Servers[1].Secret", ChangeChanged, "", "", true},
+	}
+
+	if len(diff) != len(tests) {
+		t.Fatalf("Wrong change count : got %d, want %d", len(diff), len(tests))
+	}
+
+	for i, test := range tests {
+		change := diff[i]
+		if change.Path != test.path || change.Type != test.changeType ||
+			change.Old != test.old || change.New != test.new || change.Masked != test.masked {
+			t.Errorf("Wrong change (%d) : got %+v, want %+v", i, change, test)
+		}
+	}
+
+	// Masked values must never be output.
+	js, _ := json.Marshal(diff)
+	for _, secret := range []string{"hunter2", "secret1", "secret2", "secret3", "s3cret"} {
+		if strings.Contains(diff.String(), secret) || strings.Contains(string(js), secret) {
+			t.Errorf("Diff contains masked value %s", secret)
+		}
+	}
+
+	// Structs in maps are output without masked values.
+	s, err := displayValue(reflect.ValueOf(map[string]*testDiffServer{
+		"a": {Name: "a", Secret: "hunter2"},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to display map : %s", err)
+	}
+	if strings.Contains(s, "hunter2") {
+		t.Errorf("Display value contains masked value : %s", s)
+	}
+}
@@ -1,8 +1,85 @@
 package config
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/tokenized/logger"
+)
 
 // Fetcher defines an interface that can be used for fetching data.
 type Fetcher interface {
 	Get(context.Context, string) ([]byte, error)
 }
+
+// FetcherFunc adapts a function to the Fetcher interface.
+type FetcherFunc func(ctx context.Context, name string) ([]byte, error)
+
+// FetcherMiddleware wraps a Fetcher to add behavior such as logging, retries or caching.
+type FetcherMiddleware func(Fetcher) Fetcher
+
+// FetchMetrics records the result of each fetch.
+type FetchMetrics interface {
+	ObserveFetch(name string, duration time.Duration, err error)
+}
+
+// Get calls f.
+func (f FetcherFunc) Get(ctx context.Context, name string) ([]byte, error) {
+	return f(ctx, name)
+}
+
+// Chain wraps fetcher with each middleware. The first middleware is the outermost, so it sees
+// each call first. For example Chain(f, LoggingMiddleware(), CacheMiddleware(time.Minute),
+// RetryMiddleware(DefaultRetryPolicy)) logs every call, including cache hits, and only retries
+// calls that aren't cached.
+func Chain(fetcher Fetcher, middleware ...FetcherMiddleware) Fetcher {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		fetcher = middleware[i](fetcher)
+	}
+
+	return fetcher
+}
+
+// LoggingMiddleware logs each fetch with its duration. Fetched values are never logged.
+func LoggingMiddleware() FetcherMiddleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(ctx context.Context, name string) ([]byte, error) {
+			start := time.Now()
+			b, err := next.Get(ctx, name)
+			if err != nil {
+				logger.Warn(ctx, "Failed to fetch %s after %s : %s", name, time.Since(start), err)
+				return nil, err
+			}
+
+			logger.Info(ctx, "Fetched %s in %s", name, time.Since(start))
+			return b, nil
+		})
+	}
+}
+
+// MetricsMiddleware reports the duration and error of each fetch to metrics.
+func MetricsMiddleware(metrics FetchMetrics) FetcherMiddleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(ctx context.Context, name string) ([]byte, error) {
+			start := time.Now()
+			b, err := next.Get(ctx, name)
+			metrics.ObserveFetch(name, time.Since(start), err)
+			return b, err
+		})
+	}
+}
+
+// RetryMiddleware retries failed fetches with policy. See NewRetryFetcher.
+func RetryMiddleware(policy RetryPolicy) FetcherMiddleware {
+	return func(next Fetcher) Fetcher {
+		return NewRetryFetcher(next, policy)
+	}
+}
+
+// CacheMiddleware caches fetched values for ttl. See NewCacheFetcher, which also provides
+// invalidation.
+func CacheMiddleware(ttl time.Duration) FetcherMiddleware {
+	return func(next Fetcher) Fetcher {
+		return NewCacheFetcher(next, ttl)
+	}
+}
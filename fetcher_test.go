@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testFetchMetrics records the names and errors of fetches.
+type testFetchMetrics struct {
+	lock   sync.Mutex
+	names  []string
+	failed int
+}
+
+func (m *testFetchMetrics) ObserveFetch(name string, duration time.Duration, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.names = append(m.names, name)
+	if err != nil {
+		m.failed++
+	}
+}
+
+// testOrderMiddleware appends its name to order when a call passes through it.
+func testOrderMiddleware(name string, order *[]string) FetcherMiddleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(ctx context.Context, key string) ([]byte, error) {
+			*order = append(*order, name)
+			return next.Get(ctx, key)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	fetcher := Chain(FetcherFunc(func(ctx context.Context, name string) ([]byte, error) {
+		order = append(order, "fetcher")
+		return []byte(name), nil
+	}), testOrderMiddleware("first", &order), testOrderMiddleware("second", &order))
+
+	got, err := fetcher.Get(context.Background(), "value")
+	if err != nil {
+		t.Fatalf("Failed to get : %s", err)
+	}
+	if string(got) != "value" {
+		t.Errorf("Wrong value : got %s, want %s", got, "value")
+	}
+
+	want := []string{"first", "second", "fetcher"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("Wrong order : got %v, want %v", order, want)
+	}
+}
+
+func TestChain_Middleware(t *testing.T) {
+	ctx := context.Background()
+	throttled := errors.New("throttled")
+	source := &testFetcher{errs: []error{throttled}, value: "secret"}
+	metrics := &testFetchMetrics{}
+
+	policy := testRetryPolicy
+	policy.Retryable = func(err error) bool { return err == throttled }
+
+	fetcher := Chain(source, LoggingMiddleware(), MetricsMiddleware(metrics),
+		CacheMiddleware(time.Minute), RetryMiddleware(policy))
+
+	for i := 0; i < 3; i++ {
+		got, err := fetcher.Get(ctx, "payments")
+		if err != nil {
+			t.Fatalf("Failed to get : %s", err)
+		}
+		if string(got) != "secret" {
+			t.Errorf("Wrong value : got %s, want %s", got, "secret")
+		}
+	}
+
+	// The first call is retried once and the rest are cached.
+	if source.calls != 2 {
+		t.Errorf("Wrong calls : got %d, want %d", source.calls, 2)
+	}
+
+	if len(metrics.names) != 3 || metrics.failed != 0 {
+		t.Errorf("Wrong metrics : got %d fetches, %d failed, want 3, 0", len(metrics.names),
+			metrics.failed)
+	}
+}
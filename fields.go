@@ -0,0 +1,295 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+)
+
+var (
+	// gatherRegexp and acronymRegexp are the same expressions envconfig uses to split field names
+	// tagged with split_words.
+	gatherRegexp  = regexp.MustCompile("([^A-Z]+|[A-Z]+[^A-Z]+|[A-Z]+)")
+	acronymRegexp = regexp.MustCompile("([A-Z]+)([A-Z][^A-Z]+)")
+)
+
+// envField is a settable leaf of a config struct along with the environment variable names that
+// envconfig would use for it.
+type envField struct {
+	// Path is the Go field path from the root struct, for example "DB.Host".
+	Path string
+
+	// Key is the full environment variable name, including any prefix.
+	Key string
+
+	// Alt is the name from the envconfig tag, which envconfig also checks without a prefix.
+	Alt string
+
+	Tag   reflect.StructTag
+	Value reflect.Value
+}
+
+// envFields returns the leaf fields of cfg using the same naming rules as envconfig.Process, so
+// that the package can apply individual environment values without processing the whole struct.
+func envFields(prefix string, cfg interface{}) ([]*envField, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, envconfig.ErrInvalidSpecification
+	}
+
+	return gatherEnvFields(prefix, "", v.Elem()), nil
+}
+
+func gatherEnvFields(prefix, path string, s reflect.Value) []*envField {
+	var result []*envField
+	t := s.Type()
+	for i := 0; i < s.NumField(); i++ {
+		f := s.Field(i)
+		ft := t.Field(i)
+		if !f.CanSet() || isTrue(ft.Tag.Get("ignored")) {
+			continue
+		}
+
+		for f.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				if f.Type().Elem().Kind() != reflect.Struct {
+					break
+				}
+				f.Set(reflect.New(f.Type().Elem()))
+			}
+			f = f.Elem()
+		}
+
+		field := &envField{
+			Path:  joinPath(path, ft.Name),
+			Alt:   strings.ToUpper(ft.Tag.Get("envconfig")),
+			Tag:   ft.Tag,
+			Value: f,
+		}
+
+		key := ft.Name
+		if isTrue(ft.Tag.Get("split_words")) {
+			key = splitWords(ft.Name)
+		}
+		if len(field.Alt) > 0 {
+			key = field.Alt
+		}
+		if len(prefix) > 0 {
+			key = prefix + "_" + key
+		}
+		field.Key = strings.ToUpper(key)
+
+		if f.Kind() == reflect.Struct && !hasDecoder(f) {
+			innerPrefix := prefix
+			innerPath := path
+			if !ft.Anonymous {
+				innerPrefix = field.Key
+				innerPath = field.Path
+			}
+
+			result = append(result, gatherEnvFields(innerPrefix, innerPath, f)...)
+			continue
+		}
+
+		result = append(result, field)
+	}
+
+	return result
+}
+
+// lookup returns the environment value for the field, checking the alternate name when the full
+// key isn't set.
+func (f *envField) lookup(lookupEnv func(string) (string, bool)) (string, string, bool) {
+	if value, ok := lookupEnv(f.Key); ok {
+		return f.Key, value, true
+	}
+
+	if len(f.Alt) > 0 {
+		if value, ok := lookupEnv(f.Alt); ok {
+			return f.Alt, value, true
+		}
+	}
+
+	return "", "", false
+}
+
+// set decodes a text value into the field.
+func (f *envField) set(key, value string) error {
+	if err := decodeText(value, f.Value); err != nil {
+		return &envconfig.ParseError{
+			KeyName:   key,
+			FieldName: f.Path,
+			TypeName:  f.Value.Type().String(),
+			Value:     value,
+			Err:       err,
+		}
+	}
+
+	return nil
+}
+
+func joinPath(parent, name string) string {
+	if len(parent) == 0 {
+		return name
+	}
+	return parent + "." + name
+}
+
+func splitWords(name string) string {
+	words := gatherRegexp.FindAllStringSubmatch(name, -1)
+	if len(words) == 0 {
+		return name
+	}
+
+	var parts []string
+	for _, word := range words {
+		if m := acronymRegexp.FindStringSubmatch(word[0]); len(m) == 3 {
+			parts = append(parts, m[1], m[2])
+		} else {
+			parts = append(parts, word[0])
+		}
+	}
+
+	return strings.Join(parts, "_")
+}
+
+func isTrue(s string) bool {
+	b, _ := strconv.ParseBool(s)
+	return b
+}
+
+// hasDecoder returns true if the value decodes itself from text rather than being walked as a
+// nested struct.
+func hasDecoder(v reflect.Value) bool {
+	if !v.CanAddr() {
+		return false
+	}
+
+	switch v.Addr().Interface().(type) {
+	case envconfig.Decoder, envconfig.Setter, encoding.TextUnmarshaler,
+		encoding.BinaryUnmarshaler:
+		return true
+	}
+
+	return false
+}
+
+// decodeText sets a value from its text representation following the same rules envconfig uses
+// for environment values.
+func decodeText(value string, field reflect.Value) error {
+	if field.CanAddr() {
+		switch d := field.Addr().Interface().(type) {
+		case envconfig.Decoder:
+			return d.Decode(value)
+		case envconfig.Setter:
+			return d.Set(value)
+		case encoding.TextUnmarshaler:
+			return d.UnmarshalText([]byte(value))
+		case encoding.BinaryUnmarshaler:
+			return d.UnmarshalBinary([]byte(value))
+		}
+	}
+
+	typ := field.Type()
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		if field.IsNil() {
+			field.Set(reflect.New(typ))
+		}
+		field = field.Elem()
+		return decodeText(value, field)
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		field.SetString(value)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if typ.PkgPath() == "time" && typ.Name() == "Duration" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+
+		val, err := strconv.ParseInt(value, 0, typ.Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(val)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val, err := strconv.ParseUint(value, 0, typ.Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(val)
+
+	case reflect.Bool:
+		val, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(val)
+
+	case reflect.Float32, reflect.Float64:
+		val, err := strconv.ParseFloat(value, typ.Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(val)
+
+	case reflect.Slice:
+		sl := reflect.MakeSlice(typ, 0, 0)
+		if typ.Elem().Kind() == reflect.Uint8 {
+			sl = reflect.ValueOf([]byte(value))
+		} else if len(strings.TrimSpace(value)) != 0 {
+			vals := strings.Split(value, ",")
+			sl = reflect.MakeSlice(typ, len(vals), len(vals))
+			for i, val := range vals {
+				if err := decodeText(val, sl.Index(i)); err != nil {
+					return err
+				}
+			}
+		}
+		field.Set(sl)
+
+	case reflect.Map:
+		mp := reflect.MakeMap(typ)
+		if len(strings.TrimSpace(value)) != 0 {
+			for _, pair := range strings.Split(value, ",") {
+				kv := strings.Split(pair, ":")
+				if len(kv) != 2 {
+					return fmt.Errorf("invalid map item: %q", pair)
+				}
+
+				k := reflect.New(typ.Key()).Elem()
+				if err := decodeText(kv[0], k); err != nil {
+					return err
+				}
+
+				v := reflect.New(typ.Elem()).Elem()
+				if err := decodeText(kv[1], v); err != nil {
+					return err
+				}
+
+				mp.SetMapIndex(k, v)
+			}
+		}
+		field.Set(mp)
+
+	default:
+		return errors.Errorf("unsupported type %s", typ)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FormatJSON is the name of the JSON config file format.
+	FormatJSON = "json"
+
+	// FormatYAML is the name of the YAML config file format.
+	FormatYAML = "yaml"
+
+	// FormatTOML is the name of the TOML config file format.
+	FormatTOML = "toml"
+
+	// FormatDotEnv is the name of the dotenv config file format. Values in a dotenv file are
+	// applied with the same names and rules as environment variables.
+	FormatDotEnv = "dotenv"
+)
+
+// Decoder converts the contents of a config file into JSON, so that every format is unmarshalled
+// with the same `json` tags and custom JSON unmarshallers.
+type Decoder func(b []byte) ([]byte, error)
+
+var (
+	formatsLock sync.RWMutex
+
+	// formats maps a format name to the decoder for it.
+	formats = map[string]Decoder{
+		FormatJSON: jsonDecoder,
+		FormatYAML: yamlToJSON,
+		FormatTOML: tomlToJSON,
+	}
+
+	// extensions maps a lower case file extension to a format name.
+	extensions = map[string]string{
+		".json": FormatJSON,
+		".yaml": FormatYAML,
+		".yml":  FormatYAML,
+		".toml": FormatTOML,
+		".env":  FormatDotEnv,
+	}
+)
+
+// RegisterFormat adds a decoder for a config file format. The format is used for files with any
+// of the extensions given, or when it is named with WithFormat. Registering an existing name or
+// extension replaces it.
+//
+// The dotenv format can't be replaced, since it sets fields the same way as environment variables
+// rather than being decoded to JSON. Its ".env" extension can be used for another format.
+func RegisterFormat(name string, decoder Decoder, exts ...string) error {
+	if name == FormatDotEnv {
+		return errors.Errorf("format %s can't be replaced", name)
+	}
+
+	formatsLock.Lock()
+	defer formatsLock.Unlock()
+
+	formats[name] = decoder
+	for _, ext := range exts {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions[strings.ToLower(ext)] = name
+	}
+
+	return nil
+}
+
+// WithFormat sets the format of the config file instead of choosing it by file extension.
+func WithFormat(name string) Option {
+	return func(o *options) {
+		o.format = name
+	}
+}
+
+// fileFormat returns the format name for a file. Files with unknown extensions are JSON.
+func (o *options) fileFormat(filename string) string {
+	if len(o.format) > 0 {
+		return o.format
+	}
+
+	formatsLock.RLock()
+	defer formatsLock.RUnlock()
+
+	if name, ok := extensions[strings.ToLower(filepath.Ext(filename))]; ok {
+		return name
+	}
+
+	return FormatJSON
+}
+
+// decodeFormat converts the contents of a config file in the named format to JSON.
+func decodeFormat(name string, b []byte) ([]byte, error) {
+	formatsLock.RLock()
+	decoder, ok := formats[name]
+	formatsLock.RUnlock()
+
+	if !ok {
+		return nil, errors.Errorf("unknown format %s", name)
+	}
+
+	js, err := decoder(b)
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+
+	return js, nil
+}
+
+func jsonDecoder(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+// yamlToJSON converts a YAML document to JSON.
+func yamlToJSON(b []byte) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonCompatible(value))
+}
+
+// tomlToJSON converts a TOML document to JSON.
+func tomlToJSON(b []byte) ([]byte, error) {
+	value := make(map[string]interface{})
+	if err := toml.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonCompatible(value))
+}
+
+// parseDotEnv returns the variables defined in a dotenv file.
+func parseDotEnv(b []byte) (map[string]string, error) {
+	return godotenv.Unmarshal(string(b))
+}
+
+// jsonCompatible converts maps with non-string keys, which YAML allows, into maps with string keys
+// so the value can be marshalled as JSON.
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = jsonCompatible(item)
+		}
+		return result
+
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[fmt.Sprintf("%v", key)] = jsonCompatible(item)
+		}
+		return result
+
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = jsonCompatible(item)
+		}
+		return result
+
+	case []map[string]interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = jsonCompatible(item)
+		}
+		return result
+
+	default:
+		return value
+	}
+}
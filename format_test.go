@@ -0,0 +1,197 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type testFormatConfig struct {
+	Name     string            `json:"name"`
+	Interval Duration          `json:"interval"`
+	Ports    []int             `json:"ports"`
+	Labels   map[string]string `json:"labels"`
+	DB       testLayeredDB     `json:"db"`
+}
+
+func TestLoadFromFile_YAML(t *testing.T) {
+	filename := writeTestFile(t, "config.yaml", `
+name: from-yaml
+interval: 30s
+ports:
+  - 80
+  - 443
+labels:
+  team: payments
+db:
+  host: db.internal
+  port: 6543
+`)
+
+	cfg := &testFormatConfig{}
+	if err := LoadFromFile(filename, cfg); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.Name != "from-yaml" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "from-yaml")
+	}
+
+	if cfg.Interval.Duration != 30*time.Second {
+		t.Errorf("Wrong interval : got %s, want %s", cfg.Interval, 30*time.Second)
+	}
+
+	if len(cfg.Ports) != 2 || cfg.Ports[0] != 80 || cfg.Ports[1] != 443 {
+		t.Errorf("Wrong ports : got %v, want %v", cfg.Ports, []int{80, 443})
+	}
+
+	if cfg.Labels["team"] != "payments" {
+		t.Errorf("Wrong team label : got %s, want %s", cfg.Labels["team"], "payments")
+	}
+
+	if cfg.DB.Host != "db.internal" {
+		t.Errorf("Wrong db host : got %s, want %s", cfg.DB.Host, "db.internal")
+	}
+
+	if cfg.DB.Port != 6543 {
+		t.Errorf("Wrong db port : got %d, want %d", cfg.DB.Port, 6543)
+	}
+}
+
+func TestLoadFromFile_TOML(t *testing.T) {
+	filename := writeTestFile(t, "config.toml", `
+name = "from-toml"
+interval = "1m"
+ports = [80, 443]
+
+[db]
+host = "db.internal"
+port = 6543
+`)
+
+	cfg := &testFormatConfig{}
+	if err := LoadFromFile(filename, cfg); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.Name != "from-toml" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "from-toml")
+	}
+
+	if cfg.Interval.Duration != time.Minute {
+		t.Errorf("Wrong interval : got %s, want %s", cfg.Interval, time.Minute)
+	}
+
+	if len(cfg.Ports) != 2 {
+		t.Errorf("Wrong ports : got %v, want %v", cfg.Ports, []int{80, 443})
+	}
+
+	if cfg.DB.Port != 6543 {
+		t.Errorf("Wrong db port : got %d, want %d", cfg.DB.Port, 6543)
+	}
+}
+
+func TestLoadFromFile_DotEnv(t *testing.T) {
+	filename := writeTestFile(t, "local.env", `
+# Local development settings
+NAME=from-dotenv
+INTERVAL=2s
+PORTS=8080,8081
+DB_HOST="db.local"
+`)
+
+	cfg := &testFormatConfig{}
+	if err := LoadFromFile(filename, cfg); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.Name != "from-dotenv" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "from-dotenv")
+	}
+
+	if cfg.Interval.Duration != 2*time.Second {
+		t.Errorf("Wrong interval : got %s, want %s", cfg.Interval, 2*time.Second)
+	}
+
+	if len(cfg.Ports) != 2 || cfg.Ports[1] != 8081 {
+		t.Errorf("Wrong ports : got %v, want %v", cfg.Ports, []int{8080, 8081})
+	}
+
+	if cfg.DB.Host != "db.local" {
+		t.Errorf("Wrong db host : got %s, want %s", cfg.DB.Host, "db.local")
+	}
+
+	// Not set in the dotenv file, so the default applies.
+	if cfg.DB.Port != 5432 {
+		t.Errorf("Wrong db port : got %d, want %d", cfg.DB.Port, 5432)
+	}
+}
+
+func TestLoadFromFile_DotEnvRequired(t *testing.T) {
+	type requiredConfig struct {
+		User     string `json:"user"`
+		Password string `json:"password" required:"true"`
+	}
+
+	filename := writeTestFile(t, "local.env", "CONFIGTEST_PASSWORD=from-dotenv\n")
+
+	cfg := &requiredConfig{}
+	if err := LoadFromFile(filename, cfg, WithPrefix("CONFIGTEST")); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.Password != "from-dotenv" {
+		t.Errorf("Wrong password : got %s, want %s", cfg.Password, "from-dotenv")
+	}
+
+	filename = writeTestFile(t, "local.env", "CONFIGTEST_USER=user\n")
+
+	cfg = &requiredConfig{}
+	if err := LoadFromFile(filename, cfg, WithPrefix("CONFIGTEST")); err == nil {
+		t.Errorf("Missing required password should fail")
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	decoder := func(b []byte) ([]byte, error) {
+		return []byte(`{"name": "` + strings.TrimSpace(string(b)) + `"}`), nil
+	}
+
+	if err := RegisterFormat("test-keys", decoder, "keys"); err != nil {
+		t.Fatalf("Failed to register format : %s", err)
+	}
+	t.Cleanup(func() {
+		formatsLock.Lock()
+		defer formatsLock.Unlock()
+
+		delete(formats, "test-keys")
+		delete(extensions, ".keys")
+	})
+
+	if err := RegisterFormat(FormatDotEnv, decoder); err == nil {
+		t.Errorf("Replacing the dotenv format should fail")
+	}
+
+	filename := writeTestFile(t, "config.keys", "from-custom\n")
+
+	cfg := &testFormatConfig{}
+	if err := LoadFromFile(filename, cfg); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.Name != "from-custom" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "from-custom")
+	}
+
+	// The explicit format overrides the extension.
+	yamlFile := writeTestFile(t, "config.txt", "name: from-yaml\n")
+
+	cfg = &testFormatConfig{}
+	if err := LoadFromFile(yamlFile, cfg, WithFormat(FormatYAML)); err != nil {
+		t.Fatalf("Failed to load config with format : %s", err)
+	}
+
+	if cfg.Name != "from-yaml" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "from-yaml")
+	}
+}
@@ -3,11 +3,14 @@ module github.com/tokenized/config
 go 1.14
 
 require (
+	github.com/BurntSushi/toml v0.4.1
 	github.com/aws/aws-sdk-go v1.35.3
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/joho/godotenv v1.3.0
 	github.com/kelseyhightower/envconfig v1.4.0
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/pkg/errors v0.9.1
 	github.com/tokenized/logger v0.1.3
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// jsonField is a struct field as seen by encoding/json, including fields promoted from embedded
+// structs.
+type jsonField struct {
+	// Name is the JSON key.
+	Name string
+
+	// Path is the Go field path relative to the struct, for example "Host".
+	Path string
+
+	Type reflect.Type
+}
+
+// jsonFields returns the fields that encoding/json will unmarshal into for a struct type.
+func jsonFields(t reflect.Type) []jsonField {
+	var result []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+
+		tag := ft.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+
+		if ft.Anonymous && len(name) == 0 {
+			et := ft.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+
+			if et.Kind() == reflect.Struct {
+				// Fields of embedded structs are promoted to the parent.
+				result = append(result, jsonFields(et)...)
+				continue
+			}
+		}
+
+		if len(ft.PkgPath) > 0 {
+			continue // not exported
+		}
+
+		if len(name) == 0 {
+			name = ft.Name
+		}
+
+		result = append(result, jsonField{
+			Name: name,
+			Path: ft.Name,
+			Type: ft.Type,
+		})
+	}
+
+	return result
+}
+
+// findJSONField returns the field for a JSON key, using the same case insensitive matching as
+// encoding/json.
+func findJSONField(fields []jsonField, key string) (jsonField, bool) {
+	for _, field := range fields {
+		if field.Name == key {
+			return field, true
+		}
+	}
+
+	for _, field := range fields {
+		if strings.EqualFold(field.Name, key) {
+			return field, true
+		}
+	}
+
+	return jsonField{}, false
+}
+
+// isJSONLeaf returns true if values of the type are unmarshalled as a single value rather than by
+// walking struct fields.
+func isJSONLeaf(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+
+	pt := reflect.PtrTo(t)
+	return pt.Implements(jsonUnmarshalerType) || pt.Implements(textUnmarshalerType)
+}
+
+// jsonVisitor walks a decoded JSON value alongside the type it will be unmarshalled into.
+type jsonVisitor struct {
+	// set is called with the Go field path of every field that is set by the JSON.
+	set func(path string)
+
+	// unknown is called with the JSON key path of every key that doesn't match a field.
+	unknown func(keyPath string)
+}
+
+// walk visits value, which is unmarshalled into type t. Fields are only reported to set when
+// record is true, which is not the case inside slices and maps.
+func (v *jsonVisitor) walk(t reflect.Type, value interface{}, path, keyPath string, record bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	object, isObject := value.(map[string]interface{})
+	if isObject && !isJSONLeaf(t) {
+		fields := jsonFields(t)
+		for key, fieldValue := range object {
+			field, found := findJSONField(fields, key)
+			if !found {
+				if v.unknown != nil {
+					v.unknown(joinPath(keyPath, key))
+				}
+				continue
+			}
+
+			v.walk(field.Type, fieldValue, joinPath(path, field.Path), joinPath(keyPath, key),
+				record)
+		}
+		return
+	}
+
+	if record && len(path) > 0 && v.set != nil {
+		v.set(path)
+	}
+
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return // custom unmarshallers decide which keys are valid
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if items, ok := value.([]interface{}); ok {
+			for i, item := range items {
+				v.walk(t.Elem(), item, path, fmt.Sprintf("%s[%d]", keyPath, i), false)
+			}
+		}
+
+	case reflect.Map:
+		if isObject {
+			for key, item := range object {
+				v.walk(t.Elem(), item, path, joinPath(keyPath, key), false)
+			}
+		}
+	}
+}
+
+// jsonPaths returns the Go field paths of cfg that are set by the JSON in b.
+func jsonPaths(b []byte, cfg interface{}) ([]string, error) {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+
+	var result []string
+	v := &jsonVisitor{
+		set: func(path string) {
+			result = append(result, path)
+		},
+	}
+	v.walk(reflect.TypeOf(cfg), value, "", "", true)
+
+	return result, nil
+}
+
+// unknownJSONKeys returns the key paths in the JSON in b that don't match a field of cfg, for
+// example "db.max_conection" or "servers[1].hots".
+func unknownJSONKeys(b []byte, cfg interface{}) ([]string, error) {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+
+	var result []string
+	v := &jsonVisitor{
+		unknown: func(keyPath string) {
+			result = append(result, keyPath)
+		},
+	}
+	v.walk(reflect.TypeOf(cfg), value, "", "", false)
+	sort.Strings(result)
+
+	return result, nil
+}
@@ -0,0 +1,443 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/tokenized/logger"
+
+	"github.com/pkg/errors"
+)
+
+// Source identifies a layer that config values are loaded from.
+type Source int
+
+const (
+	// SourceDefaults sets fields from their `default` tags and calls SetDefaults on structs that
+	// implement Defaulter. See SetDefaults.
+	SourceDefaults Source = iota
+
+	// SourceFile unmarshals the config file named by CONFIG_FILE. JSON, YAML, TOML and dotenv
+	// files are supported, along with any formats added with RegisterFormat.
+	SourceFile
+
+	// SourceParamStore unmarshals the JSON value of the AWS ParamStore item named by PARAM_NAME,
+	// then sets fields from the parameters under the path in PARAM_PATH.
+	SourceParamStore
+
+	// SourceEnvironment sets fields from environment variables that are explicitly set. Fields
+	// that only have a `default` tag are left alone.
+	SourceEnvironment
+)
+
+// DefaultSources is the order that layers are applied in by LoadConfig.
+var DefaultSources = []Source{SourceDefaults, SourceFile, SourceParamStore, SourceEnvironment}
+
+// Option changes the behavior of LoadConfigWithOptions.
+type Option func(*options)
+
+type options struct {
+	sources      []Source
+	prefix       string
+	configFile   *string
+	paramName    *string
+	paramPath    *string
+	envOverrides bool
+	provenance   *Provenance
+	format       string
+	strictMode   StrictMode
+
+	// defaultsApplied is set when the defaults layer is loaded, so that defaults are also applied
+	// to structs in slices and maps that are loaded by later layers.
+	defaultsApplied bool
+
+	// paramValue is used as the ParamStore value instead of fetching it when it has already
+	// been fetched.
+	paramValue []byte
+
+	paramStore *ParamStore
+
+	// setPaths holds the path of every field set by a layer, even to a zero value, so that
+	// required fields can be checked after loading.
+	setPaths map[string]bool
+}
+
+// WithSources sets the layers to load, in order. Each layer is merged into the config, so values
+// from later layers override values from earlier layers.
+func WithSources(sources ...Source) Option {
+	return func(o *options) {
+		o.sources = sources
+	}
+}
+
+// WithConfigFile sets the config file to load instead of reading the CONFIG_FILE environment
+// variable. An empty name skips the file layer.
+func WithConfigFile(filename string) Option {
+	return func(o *options) {
+		o.configFile = &filename
+	}
+}
+
+// WithParamName sets the ParamStore item to load instead of reading the PARAM_NAME environment
+// variable. An empty name skips the ParamStore layer.
+func WithParamName(name string) Option {
+	return func(o *options) {
+		o.paramName = &name
+	}
+}
+
+// WithPrefix adds a prefix to the names of environment variables, including CONFIG_FILE,
+// PARAM_NAME and PARAM_PATH, so that several independently configured components can be loaded
+// in one process. For example with a prefix of "NEXIS" or "NEXIS_", a Host field is loaded from
+// NEXIS_HOST and the config file is named by NEXIS_CONFIG_FILE.
+//
+// As with envconfig, a name from an envconfig tag is also checked without the prefix.
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = strings.ToUpper(strings.TrimSuffix(prefix, "_"))
+	}
+}
+
+// WithEnvironmentOverrides makes LoadFromFile and LoadParamStore apply environment variables that
+// are explicitly set after the JSON values, so a single setting can be patched without changing
+// the file or ParamStore item. Fields that only have a `default` tag don't override JSON values.
+func WithEnvironmentOverrides() Option {
+	return func(o *options) {
+		o.envOverrides = true
+	}
+}
+
+// withParamValue uses a value that was already fetched from the ParamStore for the ParamStore
+// layer.
+func withParamValue(name string, value []byte) Option {
+	return func(o *options) {
+		o.paramName = &name
+		o.paramValue = value
+	}
+}
+
+func newOptions(opts []Option) *options {
+	result := &options{
+		sources: DefaultSources,
+
+		// Sources are always tracked so that validation errors can include them.
+		provenance: NewProvenance(),
+
+		setPaths: make(map[string]bool),
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	return result
+}
+
+// prefixed returns an environment variable name with the prefix added.
+func (o *options) prefixed(name string) string {
+	if len(o.prefix) == 0 {
+		return name
+	}
+	return o.prefix + "_" + name
+}
+
+func (o *options) getConfigFile() string {
+	if o.configFile != nil {
+		return *o.configFile
+	}
+	return os.Getenv(o.prefixed(EnvConfigFile))
+}
+
+func (o *options) getParamName() string {
+	if o.paramName != nil {
+		return *o.paramName
+	}
+	return os.Getenv(o.prefixed(EnvParamName))
+}
+
+func (s Source) String() string {
+	switch s {
+	case SourceDefaults:
+		return "defaults"
+	case SourceFile:
+		return "file"
+	case SourceParamStore:
+		return "param store"
+	case SourceEnvironment:
+		return "environment"
+	default:
+		return fmt.Sprintf("source(%d)", int(s))
+	}
+}
+
+// LoadConfigWithOptions loads config by merging each source into cfg in order.
+//
+// By default the order is struct defaults, then the config file, then the ParamStore, then
+// environment variables, so an environment variable can override a single value from a file or
+// ParamStore item. The file and ParamStore layers are skipped when CONFIG_FILE, PARAM_NAME and
+// PARAM_PATH are not set.
+//
+// Fields tagged `required:"true"` must be set by one of the layers, and are checked after all
+// layers are loaded, as are the `validate` tags. See Validate for the rules. Rules that this
+// package doesn't know, such as those of other validation packages, are skipped when loading.
+func LoadConfigWithOptions(ctx context.Context, cfg interface{}, opts ...Option) error {
+	o := newOptions(opts)
+
+	for _, source := range o.sources {
+		if err := o.loadSource(ctx, source, cfg); err != nil {
+			return errors.Wrap(err, source.String())
+		}
+	}
+
+	if err := o.checkRequired(cfg); err != nil {
+		return err
+	}
+
+	return o.validate(cfg)
+}
+
+// loadSingleSource loads cfg the way LoadFromFile, LoadParamStore and LoadParamStorePath do. The
+// defaults and environment variables are applied first, then unmarshal merges the single source
+// over them, then explicitly set environment variables are applied again when the
+// WithEnvironmentOverrides option is given.
+//
+// Required fields are checked after the source is loaded, so a dotenv file can provide them.
+func (o *options) loadSingleSource(cfg interface{}, unmarshal func(cfg interface{}) error) error {
+	if err := o.applyDefaults(cfg); err != nil {
+		return errors.Wrap(err, "defaults")
+	}
+
+	// Load values from environment definitions.
+	if err := o.applyEnvironment(cfg); err != nil {
+		return errors.Wrap(err, "load environment")
+	}
+
+	if err := unmarshal(cfg); err != nil {
+		return err
+	}
+
+	if err := o.applyEnvironmentOverrides(cfg); err != nil {
+		return err
+	}
+
+	if err := o.checkRequired(cfg); err != nil {
+		return err
+	}
+
+	return o.validate(cfg)
+}
+
+// loadSource merges a single layer into cfg.
+func (o *options) loadSource(ctx context.Context, source Source, cfg interface{}) error {
+	switch source {
+	case SourceDefaults:
+		return o.applyDefaults(cfg)
+
+	case SourceFile:
+		filename := o.getConfigFile()
+		if len(filename) == 0 {
+			return nil
+		}
+
+		logger.Info(ctx, "Loading config from file : %s", filename)
+		return o.unmarshalFile(ctx, filename, cfg)
+
+	case SourceParamStore:
+		if name := o.getParamName(); len(name) > 0 {
+			logger.Info(ctx, "Loading config from param store : %s", name)
+			if err := o.unmarshalParamStore(ctx, name, cfg); err != nil {
+				return err
+			}
+		}
+
+		if path := o.getParamPath(); len(path) > 0 {
+			logger.Info(ctx, "Loading config from param store path : %s", path)
+			if err := o.unmarshalParamPath(ctx, path, cfg); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	case SourceEnvironment:
+		logger.Info(ctx, "Loading config from environment")
+		return o.applyEnvironment(cfg)
+
+	default:
+		return errors.Errorf("unknown source %d", int(source))
+	}
+}
+
+// validate checks the `validate` tags of cfg after it is loaded. Rules that aren't known are
+// skipped, since they can be meant for another validation package.
+func (o *options) validate(cfg interface{}) error {
+	if err := validate(cfg, o.provenance, true); err != nil {
+		return errors.Wrap(err, "validate")
+	}
+
+	return nil
+}
+
+// checkRequired returns the same error as envconfig for the first field tagged `required:"true"`
+// that wasn't set by any layer and has no default. A field explicitly set to a zero value, such as
+// DEBUG=false, is accepted. Fields that are not empty were set by a Defaulter.
+func (o *options) checkRequired(cfg interface{}) error {
+	fields, err := envFields(o.prefix, cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if !isTrue(field.Tag.Get("required")) || len(field.Tag.Get("default")) > 0 ||
+			o.isSet(field.Path) || !isEmptyValue(field.Value) {
+			continue
+		}
+
+		return errors.Errorf("required key %s missing value", field.Key)
+	}
+
+	return nil
+}
+
+// isSet returns true if a layer set the field at path, or a field that contains it.
+func (o *options) isSet(path string) bool {
+	for {
+		if o.setPaths[path] {
+			return true
+		}
+
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			return false
+		}
+		path = path[:i]
+	}
+}
+
+// applyEnvironmentOverrides applies explicitly set environment variables when the
+// WithEnvironmentOverrides option is given.
+func (o *options) applyEnvironmentOverrides(cfg interface{}) error {
+	if !o.envOverrides {
+		return nil
+	}
+
+	if err := o.applyEnvironment(cfg); err != nil {
+		return errors.Wrap(err, "environment overrides")
+	}
+
+	return nil
+}
+
+// applyEnvironment sets only the fields whose environment variables are set.
+func (o *options) applyEnvironment(cfg interface{}) error {
+	return o.applyVariables(cfg, os.LookupEnv, func(key string) FieldSource {
+		return FieldSource{Source: SourceEnvironment, Name: key}
+	})
+}
+
+// applyVariables sets the fields whose environment variable names are found by lookup.
+func (o *options) applyVariables(cfg interface{}, lookup func(string) (string, bool),
+	source func(key string) FieldSource) error {
+
+	fields, err := envFields(o.prefix, cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		key, value, ok := field.lookup(lookup)
+		if !ok {
+			continue
+		}
+
+		if err := field.set(key, value); err != nil {
+			return err
+		}
+		o.record(field.Path, source(key))
+	}
+
+	return nil
+}
+
+// unmarshalFile merges the contents of a config file into cfg. The format is chosen by the
+// WithFormat option or the file extension.
+func (o *options) unmarshalFile(ctx context.Context, filename string, cfg interface{}) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errors.Wrap(err, "read file")
+	}
+
+	source := FieldSource{Source: SourceFile, Name: filename}
+	format := o.fileFormat(filename)
+
+	if format == FormatDotEnv {
+		values, err := parseDotEnv(b)
+		if err != nil {
+			return errors.Wrap(err, "dotenv")
+		}
+
+		return o.applyVariables(cfg, func(key string) (string, bool) {
+			value, ok := values[key]
+			return value, ok
+		}, func(string) FieldSource {
+			return source
+		})
+	}
+
+	js, err := decodeFormat(format, b)
+	if err != nil {
+		return err
+	}
+
+	if err := o.unmarshalJSON(ctx, js, cfg, source); err != nil {
+		return errors.Wrap(err, "json")
+	}
+
+	return nil
+}
+
+// unmarshalParamStore merges the JSON value of a ParamStore item into cfg.
+func (o *options) unmarshalParamStore(ctx context.Context, keyName string,
+	cfg interface{}) error {
+
+	b := o.paramValue
+	if b == nil {
+		var err error
+		b, err = o.getParamStore().Get(ctx, keyName)
+		if err != nil {
+			return errors.Wrap(err, "fetch param store")
+		}
+	}
+
+	return o.unmarshalJSON(ctx, b, cfg, FieldSource{Source: SourceParamStore, Name: keyName})
+}
+
+// unmarshalJSON merges JSON into cfg and records source as the source of the fields it contains.
+func (o *options) unmarshalJSON(ctx context.Context, b []byte, cfg interface{},
+	source FieldSource) error {
+
+	if err := o.checkUnknownKeys(ctx, b, cfg, source); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return err
+	}
+
+	paths, err := jsonPaths(b, cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		o.record(path, source)
+	}
+
+	// Struct items in slices and maps are decoded again over their defaults, after the paths
+	// above are recorded so that the sources recorded for the items' fields are kept.
+	return o.setJSONDefaults(b, cfg, "", source)
+}
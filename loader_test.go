@@ -0,0 +1,264 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testLayeredDB struct {
+	Host string `json:"host" default:"localhost"`
+	Port int    `json:"port" default:"5432"`
+}
+
+type testLayeredConfig struct {
+	Name    string        `json:"name" default:"service"`
+	Timeout time.Duration `json:"timeout" envconfig:"TEST_TIMEOUT" default:"5s"`
+	Debug   bool          `json:"debug"`
+	DB      testLayeredDB `json:"db"`
+}
+
+// TestMain clears the environment variables that the test configs are loaded from, so that
+// variables such as NAME or DEBUG that are already set on the machine don't change the results.
+func TestMain(m *testing.M) {
+	for _, cfg := range []interface{}{
+		&testLayeredConfig{},
+		&testParamPathConfig{},
+		&testFormatConfig{},
+		&testStrictConfig{},
+		&testValidateConfig{},
+		&testValidatorConfig{},
+		&testDefaultsConfig{},
+	} {
+		fields, err := envFields("", cfg)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, field := range fields {
+			os.Unsetenv(field.Key)
+			if len(field.Alt) > 0 {
+				os.Unsetenv(field.Alt)
+			}
+		}
+	}
+
+	for _, key := range []string{EnvConfigFile, EnvParamName, EnvParamPath} {
+		os.Unsetenv(key)
+	}
+
+	os.Exit(m.Run())
+}
+
+func writeTestFile(t *testing.T, name, contents string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir : %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write file : %s", err)
+	}
+
+	return filename
+}
+
+func setTestEnv(t *testing.T, key, value string) {
+	previous, exists := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if exists {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestLoadConfigWithOptions_Layers(t *testing.T) {
+	filename := writeTestFile(t, "config.json",
+		`{"name": "from-file", "timeout": 10000000000, "db": {"host": "db.internal"}}`)
+
+	setTestEnv(t, "DB_PORT", "6543")
+
+	ctx := context.Background()
+	cfg := &testLayeredConfig{}
+	if err := LoadConfigWithOptions(ctx, cfg, WithConfigFile(filename),
+		WithParamName("")); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.Name != "from-file" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "from-file")
+	}
+
+	// The default tag must not override the file value.
+	if cfg.Timeout != 10*time.Second {
+		t.Errorf("Wrong timeout : got %s, want %s", cfg.Timeout, 10*time.Second)
+	}
+
+	if cfg.DB.Host != "db.internal" {
+		t.Errorf("Wrong db host : got %s, want %s", cfg.DB.Host, "db.internal")
+	}
+
+	if cfg.DB.Port != 6543 {
+		t.Errorf("Wrong db port : got %d, want %d", cfg.DB.Port, 6543)
+	}
+}
+
+func TestLoadConfigWithOptions_Order(t *testing.T) {
+	filename := writeTestFile(t, "config.json", `{"name": "from-file"}`)
+
+	setTestEnv(t, "NAME", "from-env")
+
+	ctx := context.Background()
+	cfg := &testLayeredConfig{}
+	if err := LoadConfigWithOptions(ctx, cfg, WithConfigFile(filename),
+		WithSources(SourceDefaults, SourceEnvironment, SourceFile)); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.Name != "from-file" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "from-file")
+	}
+
+	if cfg.Timeout != 5*time.Second {
+		t.Errorf("Wrong timeout : got %s, want %s", cfg.Timeout, 5*time.Second)
+	}
+
+	if cfg.DB.Port != 5432 {
+		t.Errorf("Wrong db port : got %d, want %d", cfg.DB.Port, 5432)
+	}
+}
+
+func TestLoadFromFile_EnvironmentOverrides(t *testing.T) {
+	filename := writeTestFile(t, "config.json",
+		`{"name": "from-file", "timeout": 10000000000, "debug": true}`)
+
+	setTestEnv(t, "NAME", "from-env")
+
+	cfg := &testLayeredConfig{}
+	if err := LoadFromFile(filename, cfg); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.Name != "from-file" {
+		t.Errorf("Wrong name without overrides : got %s, want %s", cfg.Name, "from-file")
+	}
+
+	cfg = &testLayeredConfig{}
+	if err := LoadFromFile(filename, cfg, WithEnvironmentOverrides()); err != nil {
+		t.Fatalf("Failed to load config with overrides : %s", err)
+	}
+
+	if cfg.Name != "from-env" {
+		t.Errorf("Wrong name with overrides : got %s, want %s", cfg.Name, "from-env")
+	}
+
+	// Only default tags apply to timeout, so the file value must remain.
+	if cfg.Timeout != 10*time.Second {
+		t.Errorf("Wrong timeout with overrides : got %s, want %s", cfg.Timeout, 10*time.Second)
+	}
+
+	if !cfg.Debug {
+		t.Errorf("Wrong debug with overrides : got %t, want %t", cfg.Debug, true)
+	}
+}
+
+func TestLoadConfig_Prefix(t *testing.T) {
+	filename := writeTestFile(t, "config.json", `{"name": "from-file"}`)
+
+	setTestEnv(t, "NEXIS_CONFIG_FILE", filename)
+	setTestEnv(t, "NEXIS_DB_HOST", "nexis.internal")
+	setTestEnv(t, "DB_HOST", "other.internal")
+	setTestEnv(t, "TEST_TIMEOUT", "7s") // envconfig tag names are also checked without prefix
+
+	ctx := context.Background()
+	cfg := &testLayeredConfig{}
+	if err := LoadConfig(ctx, cfg, WithPrefix("NEXIS_"), WithParamName("")); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.Name != "from-file" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "from-file")
+	}
+
+	if cfg.DB.Host != "nexis.internal" {
+		t.Errorf("Wrong db host : got %s, want %s", cfg.DB.Host, "nexis.internal")
+	}
+
+	if cfg.Timeout != 7*time.Second {
+		t.Errorf("Wrong timeout : got %s, want %s", cfg.Timeout, 7*time.Second)
+	}
+
+	envCfg := &testLayeredConfig{}
+	if err := LoadEnvironment(envCfg, WithPrefix("nexis")); err != nil {
+		t.Fatalf("Failed to load environment : %s", err)
+	}
+
+	if envCfg.DB.Host != "nexis.internal" {
+		t.Errorf("Wrong environment db host : got %s, want %s", envCfg.DB.Host, "nexis.internal")
+	}
+}
+
+func TestLoadConfigWithOptions_Required(t *testing.T) {
+	type requiredConfig struct {
+		Token string `json:"token" required:"true"`
+		Debug bool   `json:"debug" required:"true"`
+		Count int    `json:"count" required:"true"`
+		Level string `json:"level" required:"true" default:"info"`
+		Name  string `json:"name"`
+	}
+
+	ctx := context.Background()
+	setTestEnv(t, "CONFIGTEST_DEBUG", "false")
+
+	cfg := &requiredConfig{}
+	err := LoadConfigWithOptions(ctx, cfg, WithPrefix("CONFIGTEST"), WithConfigFile(""),
+		WithParamName(""))
+	if err == nil {
+		t.Fatalf("Missing required field should fail")
+	}
+
+	want := "required key CONFIGTEST_TOKEN missing value"
+	if err.Error() != want {
+		t.Errorf("Wrong error : got %s, want %s", err, want)
+	}
+
+	// The required value can come from any layer, and explicit zero values are accepted.
+	filename := writeTestFile(t, "config.json", `{"token": "from-file", "count": 0}`)
+
+	cfg = &requiredConfig{}
+	if err := LoadConfigWithOptions(ctx, cfg, WithPrefix("CONFIGTEST"),
+		WithConfigFile(filename), WithParamName("")); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.Token != "from-file" {
+		t.Errorf("Wrong token : got %s, want %s", cfg.Token, "from-file")
+	}
+
+	if cfg.Level != "info" {
+		t.Errorf("Wrong level : got %s, want %s", cfg.Level, "info")
+	}
+
+	// A zero value that no layer set is still missing.
+	filename = writeTestFile(t, "config.json", `{"token": "from-file"}`)
+
+	cfg = &requiredConfig{}
+	err = LoadConfigWithOptions(ctx, cfg, WithPrefix("CONFIGTEST"), WithConfigFile(filename),
+		WithParamName(""))
+	if err == nil {
+		t.Fatalf("Missing required count should fail")
+	}
+
+	want = "required key CONFIGTEST_COUNT missing value"
+	if err.Error() != want {
+		t.Errorf("Wrong error : got %s, want %s", err, want)
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// SchemeFile is the URL scheme for secrets in files, for example "file:///run/secrets/db".
+	SchemeFile = "file"
+
+	// SchemeEnv is the URL scheme for secrets in environment variables, for example
+	// "env://DB_PASSWORD".
+	SchemeEnv = "env"
+)
+
+// FileFetcher reads secrets from files, such as Docker and Kubernetes secrets mounted under
+// /run/secrets. A trailing newline is removed.
+type FileFetcher struct{}
+
+// EnvFetcher reads secrets from environment variables. A trailing newline is removed.
+type EnvFetcher struct{}
+
+// NewFileFetcher returns a FileFetcher.
+func NewFileFetcher() *FileFetcher {
+	return &FileFetcher{}
+}
+
+// NewEnvFetcher returns an EnvFetcher.
+func NewEnvFetcher() *EnvFetcher {
+	return &EnvFetcher{}
+}
+
+// Get returns the contents of the file at path.
+func (f *FileFetcher) Get(ctx context.Context, path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read secret file")
+	}
+
+	return trimNewline(b), nil
+}
+
+// Get returns the value of the environment variable name. It is an error for the variable not to
+// be set.
+func (f *EnvFetcher) Get(ctx context.Context, name string) ([]byte, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, errors.Errorf("environment variable %s not set", name)
+	}
+
+	return trimNewline([]byte(value)), nil
+}
+
+// trimNewline removes one trailing newline, which editors and `echo` add to secret files.
+func trimNewline(b []byte) []byte {
+	if bytes.HasSuffix(b, []byte("\r\n")) {
+		return b[:len(b)-2]
+	}
+	return bytes.TrimSuffix(b, []byte("\n"))
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/pkg/errors"
+)
+
+// WithParamPath sets the ParamStore path to load instead of reading the PARAM_PATH environment
+// variable. An empty path skips loading by path.
+func WithParamPath(path string) Option {
+	return func(o *options) {
+		o.paramPath = &path
+	}
+}
+
+func (o *options) getParamPath() string {
+	if o.paramPath != nil {
+		return *o.paramPath
+	}
+	return os.Getenv(o.prefixed(EnvParamPath))
+}
+
+// LoadParamStorePath loads every parameter under a ParamStore path, such as "/svc/prod/", into
+// the fields of a struct.
+//
+// The rest of each parameter name after the path selects the field, with one name segment for
+// each level of nesting. Segments match the `json` name or the Go name of a field, ignoring case,
+// underscores and dashes, so "/svc/prod/db/max_connections" sets DB.MaxConnections. Map fields
+// use the next segment as the key. Values use the same format as environment variables.
+//
+// The strict mode applies to parameters that don't match a field. Values set by parameters
+// override values from the environment unless the WithEnvironmentOverrides option is given.
+func LoadParamStorePath(ctx context.Context, path string, cfg interface{},
+	opts ...Option) error {
+
+	o := newOptions(opts)
+
+	return o.loadSingleSource(cfg, func(cfg interface{}) error {
+		return o.unmarshalParamPath(ctx, path, cfg)
+	})
+}
+
+// unmarshalParamPath sets the fields of cfg from the parameters under a ParamStore path.
+func (o *options) unmarshalParamPath(ctx context.Context, path string, cfg interface{}) error {
+	params, err := o.getParamStore().ParametersByPath(ctx, path)
+	if err != nil {
+		return errors.Wrap(err, "fetch param store path")
+	}
+
+	// Sort so that the values of duplicate matches are applied in a consistent order.
+	sort.Slice(params, func(i, j int) bool {
+		return aws.StringValue(params[i].Name) < aws.StringValue(params[j].Name)
+	})
+
+	root := reflect.ValueOf(cfg)
+	if root.Kind() != reflect.Ptr || root.Elem().Kind() != reflect.Struct {
+		return errors.New("param store path requires a pointer to a struct")
+	}
+
+	var unknown []string
+	for _, param := range params {
+		name := aws.StringValue(param.Name)
+		segments := paramSegments(path, name)
+		if len(segments) == 0 {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		fieldPath, found, err := o.setParamField(root.Elem(), segments, "",
+			aws.StringValue(param.Value))
+		if err != nil {
+			return errors.Wrapf(err, "parameter %s", name)
+		}
+		if !found {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		o.record(fieldPath, FieldSource{Source: SourceParamStore, Name: name})
+	}
+
+	return o.reportUnknownKeys(ctx, unknown, FieldSource{Source: SourceParamStore, Name: path})
+}
+
+// paramSegments returns the segments of a parameter name after the path that was loaded.
+func paramSegments(path, name string) []string {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	if !strings.HasPrefix(name, prefix) {
+		return nil
+	}
+
+	var result []string
+	for _, segment := range strings.Split(name[len(prefix):], "/") {
+		if len(segment) > 0 {
+			result = append(result, segment)
+		}
+	}
+
+	return result
+}
+
+// setParamField decodes value into the field selected by segments. It returns the Go path of the
+// field, or false if no field matches. New map items start with their defaults.
+func (o *options) setParamField(v reflect.Value, segments []string, path string,
+	value string) (string, bool, error) {
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	isStruct := v.Kind() == reflect.Struct && !isJSONLeaf(v.Type()) && !hasDecoder(v)
+
+	if len(segments) == 0 {
+		if isStruct {
+			return "", false, nil
+		}
+
+		if err := decodeText(value, v); err != nil {
+			return "", false, err
+		}
+		return path, true, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if !isStruct {
+			return "", false, nil
+		}
+
+		field, found := findParamField(jsonFields(v.Type()), segments[0])
+		if !found {
+			return "", false, nil
+		}
+
+		return o.setParamField(v.FieldByName(field.Path), segments[1:],
+			joinPath(path, field.Path), value)
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return "", false, nil
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		// Map values aren't addressable, so update a copy and store it back.
+		key := reflect.ValueOf(segments[0]).Convert(v.Type().Key())
+		keyPath := fmt.Sprintf("%s[%s]", path, segments[0])
+		item := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			item.Set(existing)
+		} else if o.defaultsApplied {
+			if err := o.setItemDefaults(item, keyPath); err != nil {
+				return "", false, err
+			}
+		}
+
+		itemPath, found, err := o.setParamField(item, segments[1:], keyPath, value)
+		if err != nil || !found {
+			return "", found, err
+		}
+
+		v.SetMapIndex(key, item)
+		return itemPath, true, nil
+	}
+
+	return "", false, nil
+}
+
+// findParamField returns the field for a parameter name segment. The segment matches the JSON
+// name like encoding/json does, or the Go name ignoring case, underscores and dashes.
+func findParamField(fields []jsonField, segment string) (jsonField, bool) {
+	if field, found := findJSONField(fields, segment); found {
+		return field, true
+	}
+
+	name := simplifyParamName(segment)
+	for _, field := range fields {
+		if simplifyParamName(field.Name) == name || simplifyParamName(field.Path) == name {
+			return field, true
+		}
+	}
+
+	return jsonField{}, false
+}
+
+func simplifyParamName(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type testParamPathDB struct {
+	Host           string `json:"host"`
+	Port           int    `json:"port" default:"5432"`
+	MaxConnections int    `json:"max_connections"`
+}
+
+type testParamPathConfig struct {
+	Name    string            `json:"name" default:"service"`
+	Timeout time.Duration     `json:"timeout"`
+	Hosts   []string          `json:"hosts"`
+	DB      *testParamPathDB  `json:"db"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// testParamPathStore returns a ParamStore that serves the parameters from a test server.
+func testParamPathStore(t *testing.T, values map[string]string) *ParamStore {
+	server := newTestAWSServer(t)
+	for name, value := range values {
+		server.setParameter(name, value)
+	}
+
+	return server.paramStore()
+}
+
+func TestLoadParamStorePath(t *testing.T) {
+	paramStore := testParamPathStore(t, map[string]string{
+		"/svc/prod/timeout":            "30s",
+		"/svc/prod/hosts":              "a.internal,b.internal",
+		"/svc/prod/db/host":            "db.internal",
+		"/svc/prod/DB/MaxConnections":  "20",
+		"/svc/prod/labels/environment": "production",
+	})
+
+	cfg := &testParamPathConfig{}
+	provenance := NewProvenance()
+	if err := LoadParamStorePath(context.Background(), "/svc/prod/", cfg, WithParamStore(paramStore),
+		WithProvenance(provenance)); err != nil {
+		t.Fatalf("Failed to load param store path : %s", err)
+	}
+
+	if cfg.Name != "service" {
+		t.Errorf("Wrong name : got %s, want %s", cfg.Name, "service")
+	}
+
+	if cfg.Timeout != 30*time.Second {
+		t.Errorf("Wrong timeout : got %s, want %s", cfg.Timeout, 30*time.Second)
+	}
+
+	if !reflect.DeepEqual(cfg.Hosts, []string{"a.internal", "b.internal"}) {
+		t.Errorf("Wrong hosts : got %v", cfg.Hosts)
+	}
+
+	if cfg.DB.Host != "db.internal" {
+		t.Errorf("Wrong db host : got %s, want %s", cfg.DB.Host, "db.internal")
+	}
+
+	if cfg.DB.Port != 5432 {
+		t.Errorf("Wrong db port : got %d, want %d", cfg.DB.Port, 5432)
+	}
+
+	if cfg.DB.MaxConnections != 20 {
+		t.Errorf("Wrong db max connections : got %d, want %d", cfg.DB.MaxConnections, 20)
+	}
+
+	if cfg.Labels["environment"] != "production" {
+		t.Errorf("Wrong environment label : got %s, want %s", cfg.Labels["environment"],
+			"production")
+	}
+
+	source, ok := provenance.Get("DB.Host")
+	if !ok {
+		t.Fatalf("Missing source for DB.Host")
+	}
+	if source.String() != "param store /svc/prod/db/host" {
+		t.Errorf("Wrong source : got %s, want %s", source, "param store /svc/prod/db/host")
+	}
+
+	if _, ok := provenance.Get("Labels[environment]"); !ok {
+		t.Errorf("Missing source for Labels[environment]")
+	}
+}
+
+func TestLoadParamStorePath_Layers(t *testing.T) {
+	setTestEnv(t, "DB_HOST", "env.internal")
+
+	paramStore := testParamPathStore(t, map[string]string{
+		"/svc/prod/db/host": "db.internal",
+		"/svc/prod/db/port": "6543",
+	})
+
+	cfg := &testParamPathConfig{}
+	if err := LoadConfigWithOptions(context.Background(), cfg, WithConfigFile(""),
+		WithParamName(""), WithParamPath("/svc/prod"), WithParamStore(paramStore)); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	if cfg.DB.Host != "env.internal" {
+		t.Errorf("Wrong db host : got %s, want %s", cfg.DB.Host, "env.internal")
+	}
+
+	if cfg.DB.Port != 6543 {
+		t.Errorf("Wrong db port : got %d, want %d", cfg.DB.Port, 6543)
+	}
+}
+
+func TestLoadParamStorePath_Strict(t *testing.T) {
+	paramStore := testParamPathStore(t, map[string]string{
+		"/svc/prod/db/host":  "db.internal",
+		"/svc/prod/db/hots":  "typo",
+		"/svc/prod/db":       "not a field",
+		"/svc/production/db": "other service",
+	})
+
+	cfg := &testParamPathConfig{}
+	err := LoadParamStorePath(context.Background(), "/svc/prod", cfg, WithParamStore(paramStore),
+		WithStrictMode(StrictError))
+
+	unknownErr, ok := errors.Cause(err).(*UnknownKeysError)
+	if !ok {
+		t.Fatalf("Wrong error : got %v, want UnknownKeysError", err)
+	}
+
+	want := []string{"/svc/prod/db", "/svc/prod/db/hots"}
+	if !reflect.DeepEqual(unknownErr.Keys, want) {
+		t.Errorf("Wrong unknown keys : got %v, want %v", unknownErr.Keys, want)
+	}
+
+	cfg = &testParamPathConfig{}
+	err = LoadParamStorePath(context.Background(), "/svc/prod", cfg, WithParamStore(testParamPathStore(t,
+		map[string]string{"/svc/prod/db/port": "not a number"})))
+	if err == nil {
+		t.Errorf("Invalid value should fail")
+	}
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/pkg/errors"
+)
+
+// defaultParamStore is used when the WithParamStore option isn't given. Its client is created on
+// first use and shared by every load.
+var defaultParamStore = NewParamStore(AWSConfig{})
+
+// ParamStore fetches parameters from the AWS ParamStore. The client is created once and reused
+// for every call. Failed calls are retried with DefaultRetryPolicy unless SetRetryPolicy is used.
+type ParamStore struct {
+	config AWSConfig
+	retry  RetryPolicy
+
+	client     ssmiface.SSMAPI
+	clientLock sync.Mutex
+}
+
+// NewParamStore returns a ParamStore that creates its client from cfg when it is first used.
+func NewParamStore(cfg AWSConfig) *ParamStore {
+	return &ParamStore{
+		config: cfg,
+		retry:  DefaultRetryPolicy,
+	}
+}
+
+// NewParamStoreWithSession returns a ParamStore that uses a client created from sess. The SDK's
+// own retries are disabled for the client since calls are retried by the RetryPolicy.
+func NewParamStoreWithSession(sess client.ConfigProvider) *ParamStore {
+	return NewParamStoreWithClient(ssm.New(sess, noSDKRetries))
+}
+
+// NewParamStoreWithClient returns a ParamStore that uses client, which can be any implementation
+// of the SSM API.
+//
+// Any retries done by the client are repeated for each attempt of the RetryPolicy, so a client
+// from the SDK should be created with MaxRetries set to 0, or the RetryPolicy should be replaced
+// with SetRetryPolicy.
+func NewParamStoreWithClient(client ssmiface.SSMAPI) *ParamStore {
+	return &ParamStore{
+		client: client,
+		retry:  DefaultRetryPolicy,
+	}
+}
+
+// SetTimeout sets the limit on how long each call waits for a response. It replaces the Timeout of
+// the AWSConfig and should be set before the ParamStore is used.
+func (p *ParamStore) SetTimeout(timeout time.Duration) {
+	p.config.Timeout = timeout
+}
+
+// SetRetryPolicy sets how failed calls are retried. It should be set before the ParamStore is
+// used.
+func (p *ParamStore) SetRetryPolicy(policy RetryPolicy) {
+	p.retry = policy
+}
+
+// WithParamStore sets the ParamStore used to load config, for example to use a specific region,
+// account or local endpoint. Without it a shared client for the region in AWS_REGION is used.
+func WithParamStore(p *ParamStore) Option {
+	return func(o *options) {
+		o.paramStore = p
+	}
+}
+
+// getParamStore returns the ParamStore to load config from.
+func (o *options) getParamStore() *ParamStore {
+	if o.paramStore != nil {
+		return o.paramStore
+	}
+	return defaultParamStore
+}
+
+// Get returns the decrypted value of a parameter, so a ParamStore can be used as a Fetcher.
+//
+// The name can be followed by a query that selects a version or label of the parameter, for
+// example "/svc/db?version=3" or "/svc/db?label=live".
+func (p *ParamStore) Get(ctx context.Context, name string) ([]byte, error) {
+	selector, err := parameterSelector(name)
+	if err != nil {
+		return nil, err
+	}
+
+	param, err := p.Parameter(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(aws.StringValue(param.Value)), nil
+}
+
+// parameterSelector converts a name with a version or label query into the "name:selector" form
+// used by the ParamStore.
+func parameterSelector(name string) (string, error) {
+	i := strings.Index(name, "?")
+	if i < 0 {
+		return name, nil
+	}
+
+	query, err := url.ParseQuery(name[i+1:])
+	if err != nil {
+		return "", errors.Wrap(err, "parameter query")
+	}
+	name = name[:i]
+
+	var selector string
+	for key := range query {
+		switch key {
+		case "version", "label":
+			if len(selector) > 0 {
+				return "", errors.New("parameter version and label can't both be selected")
+			}
+			selector = query.Get(key)
+			if len(selector) == 0 {
+				return "", errors.Errorf("empty parameter %s", key)
+			}
+		default:
+			return "", errors.Errorf("unknown parameter query %s", key)
+		}
+	}
+
+	if len(selector) == 0 {
+		return name, nil
+	}
+	return name + ":" + selector, nil
+}
+
+// Parameter returns a parameter, including its decrypted value and version. A version or label
+// can be selected by adding it to the name, for example "/svc/db:3" or "/svc/db:live".
+func (p *ParamStore) Parameter(ctx context.Context, name string) (*ssm.Parameter, error) {
+	ssmsvc, err := p.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var out *ssm.GetParameterOutput
+	if err := p.retry.Do(ctx, "parameter "+name, func(ctx context.Context) error {
+		callCtx, cancel := callContext(ctx, p.config.Timeout)
+		defer cancel()
+
+		var err error
+		out, err = ssmsvc.GetParameterWithContext(callCtx, &ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		})
+		return err
+	}); err != nil {
+		return nil, errors.Wrap(err, "get parameter")
+	}
+
+	return out.Parameter, nil
+}
+
+// ParametersByPath returns all of the parameters under a path, including nested paths, with
+// their decrypted values. The timeout and retries apply to the request for each page.
+func (p *ParamStore) ParametersByPath(ctx context.Context, path string) ([]*ssm.Parameter,
+	error) {
+
+	ssmsvc, err := p.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	input := &ssm.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	}
+
+	var result []*ssm.Parameter
+	for {
+		out, err := p.parametersPage(ctx, ssmsvc, input)
+		if err != nil {
+			return nil, errors.Wrap(err, "get parameters by path")
+		}
+
+		result = append(result, out.Parameters...)
+
+		if len(aws.StringValue(out.NextToken)) == 0 {
+			return result, nil
+		}
+		input.NextToken = out.NextToken
+	}
+}
+
+// parametersPage requests a single page of parameters under a path.
+func (p *ParamStore) parametersPage(ctx context.Context, ssmsvc ssmiface.SSMAPI,
+	input *ssm.GetParametersByPathInput) (*ssm.GetParametersByPathOutput, error) {
+
+	var result *ssm.GetParametersByPathOutput
+	err := p.retry.Do(ctx, "parameters by path "+aws.StringValue(input.Path),
+		func(ctx context.Context) error {
+			callCtx, cancel := callContext(ctx, p.config.Timeout)
+			defer cancel()
+
+			var err error
+			result, err = ssmsvc.GetParametersByPathWithContext(callCtx, input)
+			return err
+		})
+
+	return result, err
+}
+
+// getClient returns the client, creating it on first use. A failure to create the client is
+// retried on the next call.
+func (p *ParamStore) getClient() (ssmiface.SSMAPI, error) {
+	p.clientLock.Lock()
+	defer p.clientLock.Unlock()
+
+	if p.client != nil {
+		return p.client, nil
+	}
+
+	sess, err := newAWSSession(p.config)
+	if err != nil {
+		return nil, err
+	}
+
+	p.client = ssm.New(sess)
+	return p.client, nil
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	"github.com/tokenized/logger"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultPollInterval is how often a ParamStorePoller fetches the parameter when no interval
+	// is given.
+	DefaultPollInterval = time.Minute
+
+	// maxPollBackoff limits how long a ParamStorePoller waits after repeated errors.
+	maxPollBackoff = 15 * time.Minute
+)
+
+// ParamStorePoller reloads config when the version of a ParamStore item changes.
+type ParamStorePoller struct {
+	store    *Store
+	name     string
+	interval time.Duration
+
+	// fetch returns the current parameter.
+	fetch func(ctx context.Context, name string) (*ssm.Parameter, error)
+
+	version int64
+}
+
+// NewParamStorePoller returns a ParamStorePoller that reloads store, using name as the ParamStore
+// item, each time a new version of the item is published. The store should already hold the
+// config loaded from the current version of the item. The parameter is fetched with the
+// ParamStore from the store's WithParamStore option, if it has one.
+//
+// An interval of zero uses DefaultPollInterval.
+func NewParamStorePoller(ctx context.Context, store *Store, name string,
+	interval time.Duration) (*ParamStorePoller, error) {
+
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+
+	result := &ParamStorePoller{
+		store:    store,
+		name:     name,
+		interval: interval,
+		fetch:    newOptions(store.opts).getParamStore().Parameter,
+	}
+
+	if err := result.initialize(ctx); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// initialize records the current version of the parameter.
+func (p *ParamStorePoller) initialize(ctx context.Context) error {
+	param, err := p.fetch(ctx, p.name)
+	if err != nil {
+		return errors.Wrap(err, "fetch param store")
+	}
+
+	p.version = version(param)
+	return nil
+}
+
+// Run polls the parameter until the context is cancelled. Errors are logged and the delay before
+// the next poll is doubled, up to a limit, while the current config is kept.
+func (p *ParamStorePoller) Run(ctx context.Context) error {
+	delay := p.interval
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		if _, err := p.poll(ctx); err != nil {
+			delay *= 2
+			if delay > maxPollBackoff {
+				delay = maxPollBackoff
+			}
+
+			logger.Error(ctx, "Failed to poll param store %s (retry in %s) : %s", p.name, delay,
+				err)
+			continue
+		}
+
+		delay = p.interval
+	}
+}
+
+// poll fetches the parameter and reloads the config if its version changed. It returns true if a
+// new config was published.
+func (p *ParamStorePoller) poll(ctx context.Context) (bool, error) {
+	param, err := p.fetch(ctx, p.name)
+	if err != nil {
+		return false, errors.Wrap(err, "fetch param store")
+	}
+
+	paramVersion := version(param)
+	if paramVersion == p.version {
+		return false, nil
+	}
+
+	logger.Info(ctx, "Reloading config from param store : %s (version %d)", p.name,
+		paramVersion)
+
+	var value []byte
+	if param.Value != nil {
+		value = []byte(*param.Value)
+	}
+
+	// Use the value already fetched rather than fetching it again in the load pipeline.
+	if err := p.store.Reload(ctx, withParamValue(p.name, value)); err != nil {
+		return false, errors.Wrap(err, "reload")
+	}
+
+	p.version = paramVersion
+	return true, nil
+}
+
+func version(param *ssm.Parameter) int64 {
+	if param.Version == nil {
+		return 0
+	}
+	return *param.Version
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// testParamStore serves a parameter from memory.
+type testParamStore struct {
+	lock    sync.Mutex
+	value   string
+	version int64
+	err     error
+}
+
+func (s *testParamStore) fetch(ctx context.Context, name string) (*ssm.Parameter, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &ssm.Parameter{
+		Name:    aws.String(name),
+		Value:   aws.String(s.value),
+		Version: aws.Int64(s.version),
+	}, nil
+}
+
+func (s *testParamStore) set(value string, version int64, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.value = value
+	s.version = version
+	s.err = err
+}
+
+func TestParamStorePoller(t *testing.T) {
+	store := &testParamStore{value: `{"name": "first"}`, version: 1}
+
+	ctx := context.Background()
+	cfgStore, err := LoadStore(ctx, &testLayeredConfig{}, WithConfigFile(""),
+		withParamValue("/svc/config", []byte(store.value)))
+	if err != nil {
+		t.Fatalf("Failed to load store : %s", err)
+	}
+
+	p := &ParamStorePoller{
+		store:    cfgStore,
+		name:     "/svc/config",
+		interval: DefaultPollInterval,
+		fetch:    store.fetch,
+	}
+
+	if err := p.initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize : %s", err)
+	}
+
+	if name := cfgStore.Get().(*testLayeredConfig).Name; name != "first" {
+		t.Fatalf("Wrong initial name : got %s, want %s", name, "first")
+	}
+
+	var changes [][2]string
+	cfgStore.Subscribe(func(old, new interface{}) {
+		changes = append(changes, [2]string{old.(*testLayeredConfig).Name,
+			new.(*testLayeredConfig).Name})
+	})
+
+	// Same version doesn't reload.
+	store.set(`{"name": "ignored"}`, 1, nil)
+	if changed, err := p.poll(ctx); err != nil || changed {
+		t.Fatalf("Same version should not reload : changed %t, err %v", changed, err)
+	}
+
+	// Errors keep the current config.
+	store.set("", 0, errors.New("throttled"))
+	if _, err := p.poll(ctx); err == nil {
+		t.Fatalf("Poll should have failed")
+	}
+
+	store.set(`{"name": `, 2, nil)
+	if _, err := p.poll(ctx); err == nil {
+		t.Fatalf("Poll of bad JSON should have failed")
+	}
+
+	if name := cfgStore.Get().(*testLayeredConfig).Name; name != "first" {
+		t.Errorf("Wrong name after errors : got %s, want %s", name, "first")
+	}
+
+	store.set(`{"name": "second"}`, 3, nil)
+	if changed, err := p.poll(ctx); err != nil || !changed {
+		t.Fatalf("New version should reload : changed %t, err %v", changed, err)
+	}
+
+	if name := cfgStore.Get().(*testLayeredConfig).Name; name != "second" {
+		t.Errorf("Wrong name after reload : got %s, want %s", name, "second")
+	}
+
+	if len(changes) != 1 || changes[0] != [2]string{"first", "second"} {
+		t.Errorf("Wrong changes : got %v", changes)
+	}
+}
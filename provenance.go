@@ -0,0 +1,155 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tokenized/logger"
+)
+
+// FieldSource describes where the value of a config field came from.
+type FieldSource struct {
+	Source Source
+
+	// Name is the environment variable name, file path or ParamStore item name that set the
+	// value. It is empty for struct defaults.
+	Name string
+}
+
+// Provenance records which source set each config field. It is filled in by passing it to a load
+// function with the WithProvenance option, and must not be read while that load is running. A
+// Store records each reload into a new Provenance, see Store.Provenance.
+type Provenance struct {
+	fields map[string]FieldSource
+}
+
+// NewProvenance returns an empty Provenance.
+func NewProvenance() *Provenance {
+	return &Provenance{
+		fields: make(map[string]FieldSource),
+	}
+}
+
+// WithProvenance records the source of each field into p while loading.
+func WithProvenance(p *Provenance) Option {
+	return func(o *options) {
+		o.provenance = p
+	}
+}
+
+func (s FieldSource) String() string {
+	switch s.Source {
+	case SourceDefaults:
+		return "default"
+	case SourceEnvironment:
+		return "env " + s.Name
+	default:
+		return s.Source.String() + " " + s.Name
+	}
+}
+
+// Get returns the source of the field at path, for example "DB.Host".
+func (p *Provenance) Get(path string) (FieldSource, bool) {
+	source, ok := p.fields[path]
+	return source, ok
+}
+
+// lookup returns the source of the field at path, or of the closest parent field with a recorded
+// source.
+func (p *Provenance) lookup(path string) (FieldSource, bool) {
+	for {
+		if source, ok := p.fields[path]; ok {
+			return source, true
+		}
+
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			return FieldSource{}, false
+		}
+		path = path[:i]
+	}
+}
+
+// Paths returns the paths of all fields with a recorded source, sorted.
+func (p *Provenance) Paths() []string {
+	result := make([]string, 0, len(p.fields))
+	for path := range p.fields {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// String returns a report with a line for each field and its source.
+func (p *Provenance) String() string {
+	var lines []string
+	for _, path := range p.Paths() {
+		lines = append(lines, fmt.Sprintf("%s : %s", path, p.fields[path]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Report returns a line for each field of cfg that has a recorded source, with its masked value.
+func (p *Provenance) Report(cfg interface{}) string {
+	values := make(map[string]interface{})
+	flattenMasked(Mask(cfg), "", values)
+
+	var lines []string
+	for _, path := range p.Paths() {
+		value, ok := values[path]
+		if !ok {
+			value = ""
+		}
+
+		lines = append(lines, fmt.Sprintf("%s = %v (%s)", path, value, p.fields[path]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// set records the source of a field. Later sources replace the source of the field itself and
+// any fields within it.
+func (p *Provenance) set(path string, source FieldSource) {
+	for existing := range p.fields {
+		if strings.HasPrefix(existing, path+".") {
+			delete(p.fields, existing)
+		}
+	}
+
+	p.fields[path] = source
+}
+
+// flattenMasked converts the nested map returned by Mask into field paths.
+func flattenMasked(m map[string]interface{}, parent string, result map[string]interface{}) {
+	for name, value := range m {
+		path := joinPath(parent, name)
+		if sub, ok := value.(map[string]interface{}); ok {
+			flattenMasked(sub, path, result)
+			continue
+		}
+
+		result[path] = value
+	}
+}
+
+// record notes that a field was set, and sets its source when provenance is being tracked.
+func (o *options) record(path string, source FieldSource) {
+	o.setPaths[path] = true
+
+	if o.provenance == nil {
+		return
+	}
+
+	o.provenance.set(path, source)
+}
+
+// DumpSafeWithProvenance logs a "safe" version of the config like DumpSafe, followed by the source
+// of each field.
+func DumpSafeWithProvenance(ctx context.Context, cfg interface{}, p *Provenance) {
+	DumpSafe(ctx, cfg)
+	logger.Info(ctx, "Config sources :\n%s", p.Report(cfg))
+}
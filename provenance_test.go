@@ -0,0 +1,54 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProvenance(t *testing.T) {
+	filename := writeTestFile(t, "config.json", `{"name": "from-file", "db": {"host": "db.internal"}}`)
+
+	setTestEnv(t, "DB_PORT", "6543")
+
+	ctx := context.Background()
+	cfg := &testLayeredConfig{}
+	p := NewProvenance()
+	if err := LoadConfigWithOptions(ctx, cfg, WithConfigFile(filename), WithParamName(""),
+		WithProvenance(p)); err != nil {
+		t.Fatalf("Failed to load config : %s", err)
+	}
+
+	var tests = []struct {
+		path string
+		want FieldSource
+	}{
+		{"Name", FieldSource{Source: SourceFile, Name: filename}},
+		{"Timeout", FieldSource{Source: SourceDefaults}},
+		{"DB.Host", FieldSource{Source: SourceFile, Name: filename}},
+		{"DB.Port", FieldSource{Source: SourceEnvironment, Name: "DB_PORT"}},
+	}
+
+	for _, test := range tests {
+		got, ok := p.Get(test.path)
+		if !ok {
+			t.Errorf("Missing source for %s", test.path)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("Wrong source for %s : got %s, want %s", test.path, got, test.want)
+		}
+	}
+
+	if _, ok := p.Get("Debug"); ok {
+		t.Errorf("Debug should not have a source")
+	}
+
+	report := p.Report(cfg)
+	t.Logf("Report :\n%s", report)
+
+	if !strings.Contains(report, "DB.Port = 6543 (env DB_PORT)") {
+		t.Errorf("Report missing DB.Port line")
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/tokenized/logger"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/pkg/errors"
+)
+
+// DefaultRetryPolicy is used by ParamStore and AWSSecretManager unless another policy is set.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  5,
+	InitialDelay: 100 * time.Millisecond,
+	MaxDelay:     5 * time.Second,
+	Jitter:       0.5,
+}
+
+// RetryPolicy controls how failed remote fetches are retried. The delay before each retry doubles,
+// starting at InitialDelay, up to MaxDelay.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. Values less than 2
+	// disable retries.
+	MaxAttempts int
+
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+
+	// Jitter is the fraction of each delay, from 0 to 1, that is randomized so that several
+	// processes starting together don't retry at the same time.
+	Jitter float64
+
+	// Retryable returns true for errors that should be retried. Nil uses IsRetryable.
+	Retryable func(error) bool
+}
+
+// RetryFetcher retries the Get calls of another Fetcher.
+type RetryFetcher struct {
+	fetcher Fetcher
+	policy  RetryPolicy
+}
+
+// NewRetryFetcher returns a Fetcher that retries the Get calls of fetcher with policy.
+func NewRetryFetcher(fetcher Fetcher, policy RetryPolicy) *RetryFetcher {
+	return &RetryFetcher{
+		fetcher: fetcher,
+		policy:  policy,
+	}
+}
+
+// Get returns the value from the wrapped Fetcher, retrying failures that are retryable.
+func (f *RetryFetcher) Get(ctx context.Context, name string) ([]byte, error) {
+	var result []byte
+	err := f.policy.Do(ctx, name, func(ctx context.Context) error {
+		var err error
+		result, err = f.fetcher.Get(ctx, name)
+		return err
+	})
+
+	return result, err
+}
+
+// IsRetryable returns true for errors that are likely to succeed when retried. These are AWS
+// throttling errors, server errors with a 5xx status, call timeouts and network errors.
+func IsRetryable(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case awserr.RequestFailure:
+		if cause.StatusCode() >= 500 && cause.StatusCode() != 501 {
+			return true
+		}
+		return request.IsErrorThrottle(cause) || request.IsErrorRetryable(cause)
+
+	case awserr.Error:
+		if cause.Code() == request.CanceledErrorCode {
+			// The call timed out rather than being cancelled by the caller.
+			return cause.OrigErr() == context.DeadlineExceeded
+		}
+		return request.IsErrorThrottle(cause) || request.IsErrorRetryable(cause)
+
+	case net.Error:
+		return true
+	}
+
+	return false
+}
+
+// Do calls fn until it succeeds, returns an error that isn't retryable, the maximum number of
+// attempts is reached or ctx is done. Each failed attempt is logged along with name, which
+// describes what is being fetched.
+func (p RetryPolicy) Do(ctx context.Context, name string, fn func(context.Context) error) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			if attempt > 1 {
+				logger.Info(ctx, "Fetched %s on attempt %d", name, attempt)
+			}
+			return nil
+		}
+
+		if attempt >= p.MaxAttempts || !retryable(err) || ctx.Err() != nil {
+			if attempt > 1 {
+				return errors.Wrapf(err, "%d attempts", attempt)
+			}
+			return err
+		}
+
+		delay := p.delay(attempt)
+		logger.Warn(ctx, "Failed to fetch %s on attempt %d of %d, retrying in %s : %s", name,
+			attempt, p.MaxAttempts, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "%d attempts", attempt)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// delay returns how long to wait after a failed attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.InitialDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		jitter := time.Duration(float64(delay) * p.Jitter * rand.Float64())
+		delay -= jitter
+	}
+
+	return delay
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/pkg/errors"
+)
+
+// testRetryPolicy retries quickly so tests don't wait.
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:  4,
+	InitialDelay: time.Millisecond,
+	MaxDelay:     5 * time.Millisecond,
+}
+
+// testFetcher returns an error for each call until errs is used up, then returns value.
+type testFetcher struct {
+	errs  []error
+	value string
+	calls int
+}
+
+func (f *testFetcher) Get(ctx context.Context, name string) ([]byte, error) {
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+
+	return []byte(f.value), nil
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "throttling",
+			err:  awserr.NewRequestFailure(awserr.New("ThrottlingException", "slow down", nil), 400, ""),
+			want: true,
+		},
+		{
+			name: "server error",
+			err:  awserr.NewRequestFailure(awserr.New("InternalServerError", "oops", nil), 500, ""),
+			want: true,
+		},
+		{
+			name: "not found",
+			err:  awserr.NewRequestFailure(awserr.New("ParameterNotFound", "missing", nil), 400, ""),
+			want: false,
+		},
+		{
+			name: "wrapped throttling",
+			err: errors.Wrap(awserr.NewRequestFailure(awserr.New("ThrottlingException",
+				"slow down", nil), 400, ""), "get parameter"),
+			want: true,
+		},
+		{
+			name: "network",
+			err:  &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+			want: true,
+		},
+		{
+			name: "timeout",
+			err:  awserr.New(request.CanceledErrorCode, "canceled", context.DeadlineExceeded),
+			want: true,
+		},
+		{
+			name: "cancelled",
+			err:  awserr.New(request.CanceledErrorCode, "canceled", context.Canceled),
+			want: false,
+		},
+		{
+			name: "other",
+			err:  errors.New("invalid value"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("Wrong retryable : got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryFetcher(t *testing.T) {
+	ctx := context.Background()
+	throttled := awserr.NewRequestFailure(awserr.New("ThrottlingException", "slow down", nil),
+		400, "")
+
+	fetcher := &testFetcher{errs: []error{throttled, throttled}, value: "secret"}
+	got, err := NewRetryFetcher(fetcher, testRetryPolicy).Get(ctx, "name")
+	if err != nil {
+		t.Fatalf("Failed to get : %s", err)
+	}
+	if string(got) != "secret" {
+		t.Errorf("Wrong value : got %s, want %s", got, "secret")
+	}
+	if fetcher.calls != 3 {
+		t.Errorf("Wrong calls : got %d, want %d", fetcher.calls, 3)
+	}
+
+	fetcher = &testFetcher{errs: []error{errors.New("invalid"), throttled}}
+	if _, err := NewRetryFetcher(fetcher, testRetryPolicy).Get(ctx, "name"); err == nil {
+		t.Errorf("Error that isn't retryable should fail")
+	}
+	if fetcher.calls != 1 {
+		t.Errorf("Wrong calls : got %d, want %d", fetcher.calls, 1)
+	}
+
+	fetcher = &testFetcher{errs: []error{throttled, throttled, throttled, throttled, throttled}}
+	if _, err := NewRetryFetcher(fetcher, testRetryPolicy).Get(ctx, "name"); err == nil {
+		t.Errorf("Fetch should fail after max attempts")
+	}
+	if fetcher.calls != testRetryPolicy.MaxAttempts {
+		t.Errorf("Wrong calls : got %d, want %d", fetcher.calls, testRetryPolicy.MaxAttempts)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	fetcher = &testFetcher{errs: []error{throttled}}
+	if _, err := NewRetryFetcher(fetcher, testRetryPolicy).Get(cancelled, "name"); err == nil {
+		t.Errorf("Fetch should fail when context is cancelled")
+	}
+	if fetcher.calls != 1 {
+		t.Errorf("Wrong calls : got %d, want %d", fetcher.calls, 1)
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{
+		InitialDelay: 100 * time.Millisecond,
+		MaxDelay:     time.Second,
+	}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond,
+		400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := policy.delay(i + 1); got != w {
+			t.Errorf("Wrong delay for attempt %d : got %s, want %s", i+1, got, w)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.delay(2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("Delay out of range : got %s", got)
+		}
+	}
+}
@@ -1,26 +1,99 @@
 package config
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+)
+
+const (
+	// SchemeSecretsManager is the URL scheme for secrets in AWS Secrets Manager, for example
+	// "secretsmanager://name", "secretsmanager://name?stage=AWSPENDING" or
+	// "secretsmanager://name?version=EXAMPLE1-90ab-cdef".
+	SchemeSecretsManager = "secretsmanager"
+
+	// SchemeSSM is the URL scheme for AWS ParamStore parameters, for example
+	// "ssm:///path/to/param", "ssm://param?version=3" or "ssm://param?label=live".
+	SchemeSSM = "ssm"
 )
 
 // SecretResolver looks up secrets from a source.
+//
+// Each URL scheme is resolved by the Fetcher registered for it. The Fetcher field is used for the
+// secretsmanager scheme when no other Fetcher is registered for it.
 type SecretResolver struct {
 	Fetcher Fetcher
+
+	fetchers     map[string]Fetcher
+	fetchersLock sync.RWMutex
 }
 
-// NewSecretResolver returns new SecretResolver with an AWSSecretManager as the fetcher.
+// NewSecretResolver returns new SecretResolver with an AWSSecretManager as the fetcher. The ssm,
+// file and env schemes are also registered, so the same config values can refer to mounted secret
+// files or environment variables locally and to Secrets Manager or the ParamStore in production.
 func NewSecretResolver() *SecretResolver {
-	return &SecretResolver{
+	result := &SecretResolver{
 		Fetcher: NewAWSSecretManager(),
 	}
+
+	result.Register(SchemeSSM, defaultParamStore)
+	result.Register(SchemeFile, NewFileFetcher())
+	result.Register(SchemeEnv, NewEnvFetcher())
+
+	return result
+}
+
+// Register sets the Fetcher for a URL scheme, wrapped with any middleware. See Chain for the order
+// the middleware is applied in.
+//
+// The Fetcher is called with the host and path of the URL, followed by the query if there is one,
+// for example "payments" for "secretsmanager://payments", "/run/secrets/db" for
+// "file:///run/secrets/db" or "/svc/db?version=3" for "ssm:///svc/db?version=3".
+func (r *SecretResolver) Register(scheme string, fetcher Fetcher,
+	middleware ...FetcherMiddleware) {
+
+	r.fetchersLock.Lock()
+	defer r.fetchersLock.Unlock()
+
+	if r.fetchers == nil {
+		r.fetchers = make(map[string]Fetcher)
+	}
+	r.fetchers[strings.ToLower(scheme)] = Chain(fetcher, middleware...)
+}
+
+// fetcher returns the Fetcher for a URL scheme, or nil if there isn't one.
+func (r *SecretResolver) fetcher(scheme string) Fetcher {
+	r.fetchersLock.RLock()
+	fetcher, ok := r.fetchers[scheme]
+	r.fetchersLock.RUnlock()
+
+	if ok {
+		return fetcher
+	}
+
+	if scheme == SchemeSecretsManager && r.Fetcher != nil {
+		return r.Fetcher
+	}
+
+	return nil
 }
 
 // Resolve resolves the secret if needed, and returns the value.
+//
+// Values with no scheme or a postgres scheme are returned as they are. Other values are fetched
+// with the Fetcher for their scheme. When the URL has a fragment the fetched value is parsed as
+// JSON and the fragment selects a single value from it, either a top level key such as
+// "secretsmanager://payments#api_key" or a JSON Pointer such as "ssm:///svc/api#/keys/0".
+//
+// Without a fragment, Secrets Manager secrets holding RDS connection details, with at least an
+// engine and host, are returned as a connection string. All other values are returned as they
+// are fetched.
 func (r *SecretResolver) Resolve(ctx context.Context, val string) (*string, error) {
 	u, err := url.Parse(val)
 	if err != nil {
@@ -32,30 +105,114 @@ func (r *SecretResolver) Resolve(ctx context.Context, val string) (*string, erro
 		return &val, nil
 	}
 
-	if u.Scheme != "secretsmanager" {
+	scheme := strings.ToLower(u.Scheme)
+	fetcher := r.fetcher(scheme)
+	if fetcher == nil {
 		// we do not know how to resolve this url
 		return nil, errors.New("Unresolvable")
 	}
 
-	// We need to retreive the connection string details from the secret manager.
-	//
-	// The secret name is in the host part of the url.
-	name := u.Host
+	// The secret name is in the host and path parts of the url.
+	name := u.Host + u.Path
+	if len(u.RawQuery) > 0 {
+		name += "?" + u.RawQuery
+	}
 
-	// get the value from the secret mananager
-	b, err := r.Fetcher.Get(ctx, name)
+	// get the value from the fetcher
+	b, err := fetcher.Get(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 
-	// the DB secrets are stored in JSON format for RDS, so we need to parse the value.
-	secret := RDSSecret{}
-	if err := json.Unmarshal(b, &secret); err != nil {
-		return nil, err
+	if len(u.Fragment) > 0 {
+		if b, err = selectJSON(b, u.Fragment); err != nil {
+			return nil, err
+		}
+
+		result := string(b)
+		return &result, nil
+	}
+
+	if scheme == SchemeSecretsManager {
+		// the DB secrets are stored in JSON format for RDS, so we need to parse the value.
+		if secret, ok := rdsSecret(b); ok {
+			// build the connection string from the connection string elements.
+			connStr := secret.ConnectionString()
+			return &connStr, nil
+		}
+	}
+
+	result := string(b)
+	return &result, nil
+}
+
+// rdsSecret returns the RDS connection details in a secret, if it has them.
+func rdsSecret(b []byte) (*RDSSecret, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, false
+	}
+
+	if _, ok := fields["engine"]; !ok {
+		return nil, false
+	}
+	if _, ok := fields["host"]; !ok {
+		return nil, false
+	}
+
+	secret := &RDSSecret{}
+	if err := json.Unmarshal(b, secret); err != nil {
+		return nil, false
+	}
+
+	return secret, true
+}
+
+// selectJSON returns a single value from a JSON document. The selector is either a top level key
+// or a JSON Pointer starting with "/". Strings are returned without quotes and other values as
+// JSON.
+func selectJSON(b []byte, selector string) ([]byte, error) {
+	// Numbers are kept as they are written rather than converted to floats.
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("secret is not JSON : %s", err)
+	}
+
+	tokens := []string{selector}
+	if strings.HasPrefix(selector, "/") {
+		tokens = strings.Split(selector[1:], "/")
+		for i, token := range tokens {
+			tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		}
 	}
 
-	// build the connection string from the connection string elements.
-	connStr := secret.ConnectionString()
+	for _, token := range tokens {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			item, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("secret has no key %s", selector)
+			}
+			value = item
+
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("secret has no key %s", selector)
+			}
+			value = v[i]
+
+		default:
+			return nil, fmt.Errorf("secret has no key %s", selector)
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return []byte(s), nil
+	}
 
-	return &connStr, nil
+	return json.Marshal(value)
 }
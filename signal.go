@@ -0,0 +1,47 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tokenized/logger"
+)
+
+// ReloadOnHangup reloads the store each time the process receives SIGHUP, until the context is
+// cancelled. The changed fields are logged with sensitive values masked. If a reload fails the
+// error is logged and the current config is kept.
+func ReloadOnHangup(ctx context.Context, store *Store) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			logger.Info(ctx, "Received SIGHUP, reloading config")
+			reloadAndLog(ctx, store)
+		}
+	}
+}
+
+// reloadAndLog reloads the store and logs the masked fields that changed.
+func reloadAndLog(ctx context.Context, store *Store) error {
+	old := store.Get()
+	if err := store.Reload(ctx); err != nil {
+		logger.Error(ctx, "Failed to reload config, keeping current config : %s", err)
+		return err
+	}
+
+	diff, err := Diff(old, store.Get())
+	if err != nil {
+		logger.Error(ctx, "Failed to diff config : %s", err)
+		return nil
+	}
+
+	logger.Info(ctx, "Config reloaded :\n%s", diff)
+	return nil
+}
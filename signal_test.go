@@ -0,0 +1,81 @@
+//go:build !windows
+// +build !windows
+
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloadOnHangup(t *testing.T) {
+	filename := writeTestFile(t, "config.json", `{"name": "first"}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store, err := LoadStore(ctx, &testLayeredConfig{}, WithConfigFile(filename),
+		WithParamName(""))
+	if err != nil {
+		t.Fatalf("Failed to load store : %s", err)
+	}
+
+	changes := make(chan string, 10)
+	store.Subscribe(func(old, new interface{}) {
+		changes <- new.(*testLayeredConfig).Name
+	})
+
+	// Catch SIGHUP in the test too so that a signal sent before the handler is registered doesn't
+	// terminate the process.
+	caught := make(chan os.Signal, 10)
+	signal.Notify(caught, syscall.SIGHUP)
+	defer signal.Stop(caught)
+
+	done := make(chan struct{})
+	go func() {
+		ReloadOnHangup(ctx, store)
+		close(done)
+	}()
+
+	if err := ioutil.WriteFile(filename, []byte(`{"name": "second"}`), 0644); err != nil {
+		t.Fatalf("Failed to write file : %s", err)
+	}
+
+	// Wait for the signal handler to be registered.
+	deadline := time.After(2 * time.Second)
+	for received := false; !received; {
+		syscall.Kill(os.Getpid(), syscall.SIGHUP)
+
+		select {
+		case name := <-changes:
+			if name != "second" {
+				t.Errorf("Wrong name : got %s, want %s", name, "second")
+			}
+			received = true
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("Timed out waiting for reload")
+		}
+	}
+
+	// A failed reload keeps the current config.
+	if err := ioutil.WriteFile(filename, []byte(`{"name": `), 0644); err != nil {
+		t.Fatalf("Failed to write file : %s", err)
+	}
+
+	if err := reloadAndLog(ctx, store); err == nil {
+		t.Errorf("Reload of bad file should fail")
+	}
+
+	if name := store.Get().(*testLayeredConfig).Name; name != "second" {
+		t.Errorf("Wrong name after failed reload : got %s, want %s", name, "second")
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ChangeHandler is called with the previous and new config after a reload. Both are pointers to
+// the config struct and must not be modified.
+type ChangeHandler func(old, new interface{})
+
+// Store holds the current config so that it can be safely read while it is being reloaded. The
+// loader, FileWatcher and ParamStorePoller publish new values into it.
+//
+// Each value published is a separate struct that is never modified after it is published, so a
+// value returned by Get stays consistent even if a reload happens while it is in use. The same
+// applies to the Provenance recorded by each load, which is published along with the config.
+type Store struct {
+	value   atomic.Value // storeValue
+	cfgType reflect.Type
+	opts    []Option
+
+	// publishLock serializes publishing so that handlers see changes in order.
+	publishLock sync.Mutex
+
+	handlers     map[int]ChangeHandler
+	nextID       int
+	handlersLock sync.Mutex
+}
+
+// storeValue is a published config along with the sources of its fields.
+type storeValue struct {
+	cfg        interface{}
+	provenance *Provenance
+}
+
+// NewStore returns a Store holding cfg, which must be a pointer to a struct. cfg must not be
+// modified after it is added to the Store.
+func NewStore(cfg interface{}) *Store {
+	result := &Store{
+		cfgType:  reflect.TypeOf(cfg).Elem(),
+		handlers: make(map[int]ChangeHandler),
+	}
+	result.value.Store(storeValue{cfg: cfg})
+
+	return result
+}
+
+// LoadStore loads cfg with LoadConfigWithOptions and returns a Store holding it. Reloads use the
+// same options.
+//
+// A Provenance given with WithProvenance is only filled in by this first load. Each reload records
+// into a new Provenance, which is returned by the Provenance method.
+func LoadStore(ctx context.Context, cfg interface{}, opts ...Option) (*Store, error) {
+	provenance := newOptions(opts).provenance
+	if provenance == nil {
+		provenance = NewProvenance()
+	}
+
+	allOpts := append(append([]Option{}, opts...), WithProvenance(provenance))
+	if err := LoadConfigWithOptions(ctx, cfg, allOpts...); err != nil {
+		return nil, err
+	}
+
+	result := NewStore(cfg)
+	result.opts = opts
+	result.value.Store(storeValue{cfg: cfg, provenance: provenance})
+
+	return result, nil
+}
+
+// Get returns the current config. It is a pointer to the config struct and must not be modified.
+func (s *Store) Get() interface{} {
+	return s.value.Load().(storeValue).cfg
+}
+
+// Provenance returns the sources of the fields of the current config. It is nil when the config
+// wasn't loaded by the Store, such as one given to NewStore or Publish. It must not be modified.
+func (s *Store) Provenance() *Provenance {
+	return s.value.Load().(storeValue).provenance
+}
+
+// Snapshot copies the current config into dst, which must be a pointer to the same struct type.
+// The copy is shallow, so slices and maps are shared with the Store and must not be modified.
+func (s *Store) Snapshot(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Type() != s.cfgType {
+		return errors.Errorf("snapshot requires *%s, got %T", s.cfgType, dst)
+	}
+
+	v.Elem().Set(reflect.ValueOf(s.Get()).Elem())
+	return nil
+}
+
+// Subscribe adds a handler that is called each time a new config is published. It returns an id
+// that can be passed to Unsubscribe.
+//
+// Handlers are called one at a time, in the order changes are published, and must not publish or
+// reload the Store themselves.
+func (s *Store) Subscribe(handler ChangeHandler) int {
+	s.handlersLock.Lock()
+	defer s.handlersLock.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.handlers[id] = handler
+
+	return id
+}
+
+// Unsubscribe removes a handler added with Subscribe.
+func (s *Store) Unsubscribe(id int) {
+	s.handlersLock.Lock()
+	defer s.handlersLock.Unlock()
+
+	delete(s.handlers, id)
+}
+
+// Publish replaces the current config with cfg, which must be a pointer to the same struct type,
+// and notifies subscribers. cfg must not be modified after it is published.
+func (s *Store) Publish(cfg interface{}) error {
+	s.publishLock.Lock()
+	defer s.publishLock.Unlock()
+
+	return s.publish(cfg, nil)
+}
+
+// Reload runs the load pipeline into a new config and publishes it. The options given are added
+// to the options the Store was loaded with. If the load fails the current config is kept.
+func (s *Store) Reload(ctx context.Context, opts ...Option) error {
+	s.publishLock.Lock()
+	defer s.publishLock.Unlock()
+
+	// Each load records into its own Provenance so that the one published with the previous
+	// config is never modified.
+	provenance := NewProvenance()
+
+	cfg := reflect.New(s.cfgType).Interface()
+	allOpts := append(append([]Option{}, s.opts...), opts...)
+	allOpts = append(allOpts, WithProvenance(provenance))
+	if err := LoadConfigWithOptions(ctx, cfg, allOpts...); err != nil {
+		return err
+	}
+
+	return s.publish(cfg, provenance)
+}
+
+// publish must be called with the publish lock held.
+func (s *Store) publish(cfg interface{}, provenance *Provenance) error {
+	if t := reflect.TypeOf(cfg); t.Kind() != reflect.Ptr || t.Elem() != s.cfgType {
+		return errors.Errorf("publish requires *%s, got %T", s.cfgType, cfg)
+	}
+
+	old := s.Get()
+	s.value.Store(storeValue{cfg: cfg, provenance: provenance})
+
+	s.handlersLock.Lock()
+	handlers := make([]ChangeHandler, 0, len(s.handlers))
+	for _, handler := range s.handlers {
+		handlers = append(handlers, handler)
+	}
+	s.handlersLock.Unlock()
+
+	for _, handler := range handlers {
+		handler(old, cfg)
+	}
+
+	return nil
+}
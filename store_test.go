@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	first := &testLayeredConfig{Name: "first"}
+	store := NewStore(first)
+
+	var changes [][2]string
+	id := store.Subscribe(func(old, new interface{}) {
+		changes = append(changes, [2]string{old.(*testLayeredConfig).Name,
+			new.(*testLayeredConfig).Name})
+	})
+
+	if err := store.Publish(&testLayeredConfig{Name: "second"}); err != nil {
+		t.Fatalf("Failed to publish : %s", err)
+	}
+
+	if err := store.Publish(testLayeredConfig{Name: "wrong"}); err == nil {
+		t.Fatalf("Publish of non pointer should fail")
+	}
+
+	var snapshot testLayeredConfig
+	if err := store.Snapshot(&snapshot); err != nil {
+		t.Fatalf("Failed to snapshot : %s", err)
+	}
+
+	if snapshot.Name != "second" {
+		t.Errorf("Wrong snapshot name : got %s, want %s", snapshot.Name, "second")
+	}
+
+	// Modifying the snapshot must not change the store.
+	snapshot.Name = "modified"
+	if name := store.Get().(*testLayeredConfig).Name; name != "second" {
+		t.Errorf("Wrong name after snapshot change : got %s, want %s", name, "second")
+	}
+
+	if err := store.Snapshot(&testStrictConfig{}); err == nil {
+		t.Errorf("Snapshot of wrong type should fail")
+	}
+
+	store.Unsubscribe(id)
+
+	filename := writeTestFile(t, "config.json", `{"name": "third"}`)
+	if err := store.Reload(context.Background(), WithConfigFile(filename),
+		WithParamName("")); err != nil {
+		t.Fatalf("Failed to reload : %s", err)
+	}
+
+	if name := store.Get().(*testLayeredConfig).Name; name != "third" {
+		t.Errorf("Wrong name after reload : got %s, want %s", name, "third")
+	}
+
+	if len(changes) != 1 || changes[0] != [2]string{"first", "second"} {
+		t.Errorf("Wrong changes : got %v", changes)
+	}
+
+	// The first value is never modified by later publishes.
+	if first.Name != "first" {
+		t.Errorf("First value was modified : got %s, want %s", first.Name, "first")
+	}
+}
+
+func TestLoadStore_Provenance(t *testing.T) {
+	ctx := context.Background()
+	first := writeTestFile(t, "first.json", `{"name": "first"}`)
+	second := writeTestFile(t, "second.json", `{"debug": true}`)
+
+	p := NewProvenance()
+	store, err := LoadStore(ctx, &testLayeredConfig{}, WithConfigFile(first), WithParamName(""),
+		WithProvenance(p))
+	if err != nil {
+		t.Fatalf("Failed to load store : %s", err)
+	}
+
+	if store.Provenance() != p {
+		t.Errorf("Store should publish the provenance of the first load")
+	}
+
+	if err := store.Reload(ctx, WithConfigFile(second)); err != nil {
+		t.Fatalf("Failed to reload : %s", err)
+	}
+
+	// The reload records into a new Provenance, so p is not modified.
+	if source, _ := p.Get("Name"); source.Source != SourceFile || source.Name != first {
+		t.Errorf("Wrong first name source : got %s", source)
+	}
+	if _, ok := p.Get("Debug"); ok {
+		t.Errorf("First provenance was modified by reload")
+	}
+
+	reloaded := store.Provenance()
+	if reloaded == p {
+		t.Fatalf("Reload should publish a new provenance")
+	}
+
+	if source, _ := reloaded.Get("Name"); source.Source != SourceDefaults {
+		t.Errorf("Wrong reloaded name source : got %s, want %s", source,
+			FieldSource{Source: SourceDefaults})
+	}
+	if source, _ := reloaded.Get("Debug"); source.Source != SourceFile || source.Name != second {
+		t.Errorf("Wrong reloaded debug source : got %s", source)
+	}
+
+	if err := store.Publish(&testLayeredConfig{Name: "published"}); err != nil {
+		t.Fatalf("Failed to publish : %s", err)
+	}
+	if store.Provenance() != nil {
+		t.Errorf("Published config should have no provenance")
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tokenized/logger"
+)
+
+// StrictMode controls what happens when a JSON config contains keys that don't match a field.
+type StrictMode int
+
+const (
+	// StrictOff ignores unknown keys, which is the behavior of json.Unmarshal.
+	StrictOff StrictMode = iota
+
+	// StrictWarn logs a warning listing the unknown keys.
+	StrictWarn
+
+	// StrictError fails the load with an UnknownKeysError.
+	StrictError
+)
+
+// UnknownKeysError is returned in StrictError mode when a config contains keys that don't match a
+// field.
+type UnknownKeysError struct {
+	// Source is the file or ParamStore item containing the keys.
+	Source FieldSource
+
+	// Keys are the full key paths, for example "db.max_conection". For a ParamStore path they
+	// are the parameter names.
+	Keys []string
+}
+
+// WithStrictMode checks config files and ParamStore values for keys that don't match a field of
+// the config, including keys within nested structs and slices of structs. Parameters loaded from
+// a ParamStore path are checked the same way.
+func WithStrictMode(mode StrictMode) Option {
+	return func(o *options) {
+		o.strictMode = mode
+	}
+}
+
+func (e *UnknownKeysError) Error() string {
+	return "unknown keys in " + e.Source.String() + " : " + strings.Join(e.Keys, ", ")
+}
+
+// checkUnknownKeys applies the strict mode to a JSON value that is being unmarshalled into cfg.
+func (o *options) checkUnknownKeys(ctx context.Context, b []byte, cfg interface{},
+	source FieldSource) error {
+
+	if o.strictMode == StrictOff {
+		return nil
+	}
+
+	keys, err := unknownJSONKeys(b, cfg)
+	if err != nil {
+		return err
+	}
+
+	return o.reportUnknownKeys(ctx, keys, source)
+}
+
+// reportUnknownKeys logs or returns an error for keys that don't match a field, depending on the
+// strict mode.
+func (o *options) reportUnknownKeys(ctx context.Context, keys []string,
+	source FieldSource) error {
+
+	if o.strictMode == StrictOff || len(keys) == 0 {
+		return nil
+	}
+
+	if o.strictMode == StrictWarn {
+		logger.Warn(ctx, "Unknown config keys in %s : %s", source, strings.Join(keys, ", "))
+		return nil
+	}
+
+	return &UnknownKeysError{
+		Source: source,
+		Keys:   keys,
+	}
+}
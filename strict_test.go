@@ -0,0 +1,67 @@
+package config
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type testStrictServer struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+type testStrictConfig struct {
+	MaxConnections int                `json:"max_connection"`
+	Timeout        Duration           `json:"timeout"`
+	DB             testLayeredDB      `json:"db"`
+	Servers        []testStrictServer `json:"servers"`
+	Labels         map[string]string  `json:"labels"`
+}
+
+func TestStrictMode(t *testing.T) {
+	filename := writeTestFile(t, "config.json", `{
+		"max_conection": 10,
+		"timeout": "5s",
+		"db": {"host": "db.internal", "prot": 5432},
+		"servers": [{"host": "a"}, {"hots": "b"}],
+		"labels": {"anything": "goes"}
+	}`)
+
+	ctx := context.Background()
+
+	cfg := &testStrictConfig{}
+	if err := LoadConfigWithOptions(ctx, cfg, WithConfigFile(filename), WithParamName("")); err != nil {
+		t.Fatalf("Failed to load config without strict mode : %s", err)
+	}
+
+	cfg = &testStrictConfig{}
+	if err := LoadConfigWithOptions(ctx, cfg, WithConfigFile(filename), WithParamName(""),
+		WithStrictMode(StrictWarn)); err != nil {
+		t.Fatalf("Failed to load config in warn mode : %s", err)
+	}
+
+	if cfg.DB.Host != "db.internal" {
+		t.Errorf("Wrong db host : got %s, want %s", cfg.DB.Host, "db.internal")
+	}
+
+	cfg = &testStrictConfig{}
+	err := LoadConfigWithOptions(ctx, cfg, WithConfigFile(filename), WithParamName(""),
+		WithStrictMode(StrictError))
+	if err == nil {
+		t.Fatalf("Strict mode should have failed")
+	}
+	t.Logf("Error : %s", err)
+
+	unknownErr, ok := errors.Cause(err).(*UnknownKeysError)
+	if !ok {
+		t.Fatalf("Wrong error type : %s", err)
+	}
+
+	want := []string{"db.prot", "max_conection", "servers[1].hots"}
+	if !reflect.DeepEqual(unknownErr.Keys, want) {
+		t.Errorf("Wrong unknown keys : got %v, want %v", unknownErr.Keys, want)
+	}
+}
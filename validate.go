@@ -0,0 +1,381 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	durationType       = reflect.TypeOf(time.Duration(0))
+	configDurationType = reflect.TypeOf(Duration{})
+)
+
+// Validator is implemented by config structs that have rules that can't be written as tags, such
+// as checks across fields. Validate is called on the root config and every nested struct after it
+// is loaded. Returning a *ValidationError reports failures for specific fields within the struct.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError is a validation failure for one config field.
+type FieldError struct {
+	// Path is the Go field path, for example "DB.Port".
+	Path string
+
+	// Source is where the value came from, when it is known.
+	Source *FieldSource
+
+	Err error
+}
+
+// ValidationError lists every config field that failed validation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e FieldError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Err.Error()
+	}
+	if e.Source == nil {
+		return fmt.Sprintf("%s : %s", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s (%s) : %s", e.Path, e.Source, e.Err)
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		lines = append(lines, field.Error())
+	}
+
+	return fmt.Sprintf("%d invalid config fields : %s", len(e.Fields), strings.Join(lines, "; "))
+}
+
+// Validate checks the `validate` tags of cfg, calls Validate on any structs that implement
+// Validator, and returns a *ValidationError listing every field
+// that fails. Rules are separated by commas:
+//
+//	required   the value must not be empty
+//	omitempty  the other rules are skipped when the value is empty, for optional fields
+//	min=N      numbers and durations must be at least N, strings, slices and maps must have a
+//	           length of at least N
+//	max=N      the same as min, but at most N
+//	oneof=a b  the value must be one of the space separated values
+//	url        the value must be an absolute URL
+//	hostport   the value must be a host and numeric port such as "localhost:8080"
+//	regex=E    the value must match the regular expression E, which must be the last rule
+//
+// Rules are also checked for empty values, so a zero port fails min=1, unless the field has the
+// omitempty rule.
+//
+// Validate reports rules it doesn't know as failures. The load functions skip them instead, so
+// structs with tags for other validation packages, such as `validate:"gte=1"`, still load.
+func Validate(cfg interface{}) error {
+	return validate(cfg, nil, false)
+}
+
+// unknownRuleError is the failure for a rule that isn't implemented by this package.
+type unknownRuleError struct {
+	name string
+}
+
+func (e *unknownRuleError) Error() string {
+	return "unknown validation rule " + e.name
+}
+
+// validate checks cfg, adding the source of each failing field from provenance when it isn't nil.
+// Unknown rules are skipped when skipUnknown is true.
+func validate(cfg interface{}, provenance *Provenance, skipUnknown bool) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("validate requires a pointer to a struct")
+	}
+
+	var fields []FieldError
+	validateStruct(v.Elem(), "", func(path string, err error) {
+		if _, unknown := err.(*unknownRuleError); unknown && skipUnknown {
+			return
+		}
+
+		fieldErr := FieldError{Path: path, Err: err}
+		if provenance != nil {
+			if source, ok := provenance.lookup(path); ok {
+				fieldErr.Source = &source
+			}
+		}
+
+		fields = append(fields, fieldErr)
+	})
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Fields: fields}
+}
+
+func validateStruct(s reflect.Value, parent string, fail func(path string, err error)) {
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		if len(ft.PkgPath) > 0 {
+			continue // not exported
+		}
+
+		f := s.Field(i)
+		path := joinPath(parent, ft.Name)
+		if ft.Anonymous {
+			path = parent
+		}
+
+		if tag := ft.Tag.Get("validate"); len(tag) > 0 {
+			for _, err := range validateRules(f, tag) {
+				fail(path, err)
+			}
+		}
+
+		validateNested(f, path, fail)
+	}
+
+	validateValidator(s, parent, fail)
+}
+
+// validateValidator calls Validate on a struct that implements Validator.
+func validateValidator(s reflect.Value, path string, fail func(path string, err error)) {
+	var validator Validator
+	if s.CanAddr() {
+		validator, _ = s.Addr().Interface().(Validator)
+	} else {
+		validator, _ = s.Interface().(Validator)
+	}
+
+	if validator == nil {
+		return
+	}
+
+	err := validator.Validate()
+	if err == nil {
+		return
+	}
+
+	if validationErr, ok := err.(*ValidationError); ok {
+		for _, field := range validationErr.Fields {
+			fail(joinPath(path, field.Path), field.Err)
+		}
+		return
+	}
+
+	fail(path, err)
+}
+
+// validateNested validates structs within a field.
+func validateNested(f reflect.Value, path string, fail func(path string, err error)) {
+	switch f.Kind() {
+	case reflect.Ptr:
+		if !f.IsNil() {
+			validateNested(f.Elem(), path, fail)
+		}
+
+	case reflect.Struct:
+		if !isJSONLeaf(f.Type()) {
+			validateStruct(f, path, fail)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < f.Len(); i++ {
+			validateNested(f.Index(i), fmt.Sprintf("%s[%d]", path, i), fail)
+		}
+	}
+}
+
+// validateRules returns an error for each rule in the tag that the value fails.
+func validateRules(v reflect.Value, tag string) []error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+			break
+		}
+		v = v.Elem()
+	}
+
+	rules := splitRules(tag)
+	empty := isEmptyValue(v)
+
+	// Optional fields are opted out of the other rules when they are empty.
+	skipEmpty := false
+	for _, rule := range rules {
+		if rule == "omitempty" {
+			skipEmpty = empty
+		}
+	}
+
+	var result []error
+	for _, rule := range rules {
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "omitempty":
+			continue
+
+		case "required":
+			if empty {
+				result = append(result, errors.New("is required"))
+			}
+			continue
+		}
+
+		if skipEmpty {
+			continue
+		}
+
+		if err := validateRule(v, name, param); err != nil {
+			result = append(result, err)
+		}
+	}
+
+	return result
+}
+
+// splitRules returns the comma separated rules of a validate tag.
+func splitRules(tag string) []string {
+	var result []string
+	for len(tag) > 0 {
+		var rule string
+		if strings.HasPrefix(tag, "regex=") {
+			rule, tag = tag, "" // the expression can contain commas
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			rule, tag = tag[:i], tag[i+1:]
+		} else {
+			rule, tag = tag, ""
+		}
+
+		result = append(result, rule)
+	}
+
+	return result
+}
+
+func validateRule(v reflect.Value, name, param string) error {
+	switch name {
+	case "min", "max":
+		return validateLimit(v, name, param)
+
+	case "oneof":
+		value := fmt.Sprintf("%v", v.Interface())
+		for _, option := range strings.Fields(param) {
+			if value == option {
+				return nil
+			}
+		}
+		return errors.Errorf("must be one of %s", strings.Join(strings.Fields(param), ", "))
+
+	case "url":
+		u, err := url.Parse(fmt.Sprintf("%v", v.Interface()))
+		if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			return errors.New("must be a URL")
+		}
+		return nil
+
+	case "hostport":
+		_, port, err := net.SplitHostPort(fmt.Sprintf("%v", v.Interface()))
+		if err != nil {
+			return errors.New("must be host:port")
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return errors.New("must have a numeric port")
+		}
+		return nil
+
+	case "regex":
+		re, err := regexp.Compile(param)
+		if err != nil {
+			return errors.Wrap(err, "invalid regex rule")
+		}
+		if !re.MatchString(fmt.Sprintf("%v", v.Interface())) {
+			return errors.Errorf("must match %s", param)
+		}
+		return nil
+
+	default:
+		return &unknownRuleError{name: name}
+	}
+}
+
+// validateLimit checks a min or max rule.
+func validateLimit(v reflect.Value, name, param string) error {
+	if v.Type() == configDurationType {
+		v = v.Field(0)
+	}
+
+	var value, limit float64
+	var text string
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		value = float64(v.Len())
+		l, err := strconv.Atoi(param)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s rule", name)
+		}
+		limit = float64(l)
+		text = fmt.Sprintf("have a length of %s %d", limitWord(name), l)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(param)
+			if err != nil {
+				return errors.Wrapf(err, "invalid %s rule", name)
+			}
+			value = float64(v.Int())
+			limit = float64(d)
+			text = fmt.Sprintf("be %s %s", limitWord(name), d)
+			break
+		}
+		fallthrough
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		l, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s rule", name)
+		}
+		limit = l
+		text = fmt.Sprintf("be %s %s", limitWord(name), param)
+
+		switch v.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			value = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			value = v.Float()
+		default:
+			value = float64(v.Int())
+		}
+
+	default:
+		return errors.Errorf("%s rule not supported for %s", name, v.Type())
+	}
+
+	if (name == "min" && value < limit) || (name == "max" && value > limit) {
+		return errors.New("must " + text)
+	}
+
+	return nil
+}
+
+func limitWord(name string) string {
+	if name == "min" {
+		return "at least"
+	}
+	return "at most"
+}
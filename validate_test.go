@@ -0,0 +1,228 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type testValidateServer struct {
+	Address string `json:"address" validate:"required,hostport"`
+}
+
+type testValidateConfig struct {
+	Name     string               `json:"name" validate:"required"`
+	Env      string               `json:"env" validate:"oneof=dev staging prod"`
+	Port     int                  `json:"port" default:"8080" validate:"min=1,max=65535"`
+	Timeout  time.Duration        `json:"timeout" validate:"max=1m"`
+	Interval Duration             `json:"interval" validate:"omitempty,min=1s"`
+	Callback string               `json:"callback" validate:"omitempty,url"`
+	ID       string               `json:"id" validate:"omitempty,regex=^[a-z]{2,4}$"`
+	Tags     []string             `json:"tags" validate:"max=2"`
+	Optional string               `json:"optional" validate:"omitempty,url"`
+	Servers  []testValidateServer `json:"servers"`
+}
+
+func TestValidate(t *testing.T) {
+	valid := &testValidateConfig{
+		Name:     "service",
+		Env:      "prod",
+		Port:     443,
+		Timeout:  time.Second,
+		Interval: NewDuration(time.Minute),
+		Callback: "https://example.com/hook",
+		ID:       "abc",
+		Tags:     []string{"a"},
+		Servers:  []testValidateServer{{Address: "localhost:8080"}},
+	}
+
+	if err := Validate(valid); err != nil {
+		t.Fatalf("Valid config failed : %s", err)
+	}
+
+	invalid := &testValidateConfig{
+		Env:      "qa",
+		Port:     70000,
+		Timeout:  time.Hour,
+		Interval: NewDuration(time.Millisecond),
+		Callback: "not a url",
+		ID:       "abcdef",
+		Tags:     []string{"a", "b", "c"},
+		Servers:  []testValidateServer{{Address: "localhost:8080"}, {Address: "localhost"}},
+	}
+
+	err := Validate(invalid)
+	if err == nil {
+		t.Fatalf("Invalid config should fail")
+	}
+	t.Logf("Error : %s", err)
+
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Wrong error type : %s", err)
+	}
+
+	want := []string{"Name", "Env", "Port", "Timeout", "Interval", "Callback", "ID", "Tags",
+		"Servers[1].Address"}
+	if len(validationErr.Fields) != len(want) {
+		t.Fatalf("Wrong error count : got %d, want %d", len(validationErr.Fields), len(want))
+	}
+
+	for i, path := range want {
+		if validationErr.Fields[i].Path != path {
+			t.Errorf("Wrong path (%d) : got %s, want %s", i, validationErr.Fields[i].Path, path)
+		}
+	}
+}
+
+func TestLoadConfigWithOptions_Validate(t *testing.T) {
+	// A port of zero fails the min rule even though it is empty, since it isn't omitempty.
+	filename := writeTestFile(t, "config.json", `{"env": "qa", "port": 0}`)
+
+	setTestEnv(t, "TIMEOUT", "1h")
+
+	cfg := &testValidateConfig{}
+	err := LoadConfigWithOptions(context.Background(), cfg, WithConfigFile(filename),
+		WithParamName(""))
+	if err == nil {
+		t.Fatalf("Invalid config should fail")
+	}
+	t.Logf("Error : %s", err)
+
+	validationErr, ok := errors.Cause(err).(*ValidationError)
+	if !ok {
+		t.Fatalf("Wrong error type : %s", err)
+	}
+
+	if len(validationErr.Fields) != 4 {
+		t.Fatalf("Wrong error count : got %d, want %d", len(validationErr.Fields), 4)
+	}
+
+	if !strings.Contains(err.Error(), "Port (file "+filename+") : must be at least 1") {
+		t.Errorf("Missing port error with source")
+	}
+
+	if !strings.Contains(err.Error(), "Env (file "+filename+") : must be one of dev, staging, prod") {
+		t.Errorf("Missing env error with source")
+	}
+
+	if !strings.Contains(err.Error(), "Timeout (env TIMEOUT) : must be at most 1m0s") {
+		t.Errorf("Missing timeout error with source")
+	}
+}
+
+func TestLoadConfigWithOptions_UnknownRules(t *testing.T) {
+	type otherConfig struct {
+		Port int    `json:"port" validate:"gte=1,min=1"`
+		Mode string `json:"mode" validate:"required,alphanum"`
+	}
+
+	filename := writeTestFile(t, "config.json", `{"port": 0, "mode": "fast"}`)
+
+	// Rules for other validation packages are skipped when loading, but known rules still apply.
+	cfg := &otherConfig{}
+	err := LoadConfigWithOptions(context.Background(), cfg, WithConfigFile(filename),
+		WithParamName(""))
+	if err == nil {
+		t.Fatalf("Invalid port should fail")
+	}
+
+	validationErr, ok := errors.Cause(err).(*ValidationError)
+	if !ok {
+		t.Fatalf("Wrong error type : %s", err)
+	}
+
+	if len(validationErr.Fields) != 1 || validationErr.Fields[0].Err.Error() != "must be at least 1" {
+		t.Errorf("Wrong errors : %s", err)
+	}
+
+	cfg.Port = 1
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatalf("Validate should report unknown rules")
+	}
+
+	if !strings.Contains(err.Error(), "unknown validation rule gte") ||
+		!strings.Contains(err.Error(), "unknown validation rule alphanum") {
+		t.Errorf("Missing unknown rule errors : %s", err)
+	}
+}
+
+type testTLS struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+}
+
+func (c *testTLS) Validate() error {
+	if len(c.Cert) > 0 && len(c.Key) == 0 {
+		return &ValidationError{Fields: []FieldError{
+			{Path: "Key", Err: errors.New("is required when cert is set")},
+		}}
+	}
+	return nil
+}
+
+type testTimeouts struct {
+	Read  time.Duration `json:"read"`
+	Write time.Duration `json:"write"`
+}
+
+func (t testTimeouts) Validate() error {
+	if t.Read >= t.Write {
+		return errors.New("read timeout must be less than write timeout")
+	}
+	return nil
+}
+
+type testValidatorConfig struct {
+	Name     string       `json:"name" validate:"required"`
+	TLS      testTLS      `json:"tls"`
+	Timeouts testTimeouts `json:"timeouts"`
+}
+
+func (c *testValidatorConfig) Validate() error {
+	if c.Name == "forbidden" {
+		return errors.New("name is forbidden")
+	}
+	return nil
+}
+
+func TestValidate_Validator(t *testing.T) {
+	cfg := &testValidatorConfig{
+		Name:     "forbidden",
+		TLS:      testTLS{Cert: "cert.pem"},
+		Timeouts: testTimeouts{Read: time.Minute, Write: time.Second},
+	}
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("Invalid config should fail")
+	}
+	t.Logf("Error : %s", err)
+
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Wrong error type : %s", err)
+	}
+
+	want := []string{"TLS.Key", "Timeouts", ""}
+	if len(validationErr.Fields) != len(want) {
+		t.Fatalf("Wrong error count : got %d, want %d", len(validationErr.Fields), len(want))
+	}
+
+	for i, path := range want {
+		if validationErr.Fields[i].Path != path {
+			t.Errorf("Wrong path (%d) : got %s, want %s", i, validationErr.Fields[i].Path, path)
+		}
+	}
+
+	cfg.Name = "allowed"
+	cfg.TLS.Key = "key.pem"
+	cfg.Timeouts.Read = time.Millisecond
+	if err := Validate(cfg); err != nil {
+		t.Errorf("Valid config failed : %s", err)
+	}
+}
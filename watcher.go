@@ -0,0 +1,98 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io/ioutil"
+	"time"
+
+	"github.com/tokenized/logger"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultWatchInterval is how often a FileWatcher checks the config file when no interval is
+	// given.
+	DefaultWatchInterval = 5 * time.Second
+)
+
+// FileWatcher reloads config when the contents of the config file change.
+//
+// The file is checked by reading and hashing it on an interval rather than with file system
+// events, so editors that write a new file and rename it, and Kubernetes ConfigMap volumes that
+// swap a symlink, are handled the same as writes to the file.
+type FileWatcher struct {
+	store    *Store
+	filename string
+	interval time.Duration
+	hash     [sha256.Size]byte
+}
+
+// NewFileWatcher returns a FileWatcher that reloads store, using filename as the config file, each
+// time the contents of the file change. The store should already hold the config loaded from the
+// current contents of the file.
+//
+// An interval of zero uses DefaultWatchInterval.
+func NewFileWatcher(store *Store, filename string, interval time.Duration) (*FileWatcher, error) {
+	if interval == 0 {
+		interval = DefaultWatchInterval
+	}
+
+	result := &FileWatcher{
+		store:    store,
+		filename: filename,
+		interval: interval,
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "read file")
+	}
+	result.hash = sha256.Sum256(b)
+
+	return result, nil
+}
+
+// Run checks the file for changes until the context is cancelled.
+func (w *FileWatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := w.check(ctx); err != nil {
+				logger.Error(ctx, "Failed to reload config from %s : %s", w.filename, err)
+			}
+		}
+	}
+}
+
+// check reloads the config if the file contents changed since the last check. It returns true if
+// a new config was published. When the load fails the current config is kept.
+func (w *FileWatcher) check(ctx context.Context) (bool, error) {
+	b, err := ioutil.ReadFile(w.filename)
+	if err != nil {
+		// The file can be briefly missing while it is being replaced.
+		return false, errors.Wrap(err, "read file")
+	}
+
+	hash := sha256.Sum256(b)
+	if bytes.Equal(hash[:], w.hash[:]) {
+		return false, nil
+	}
+
+	// Don't retry the same contents if they fail to load.
+	w.hash = hash
+
+	logger.Info(ctx, "Reloading config from file : %s", w.filename)
+	if err := w.store.Reload(ctx, WithConfigFile(w.filename)); err != nil {
+		return false, errors.Wrap(err, "reload")
+	}
+
+	return true, nil
+}
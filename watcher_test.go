@@ -0,0 +1,124 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// replaceTestFile writes a new file and renames it over the old one, the way editors and
+// Kubernetes replace files.
+func replaceTestFile(t *testing.T, filename, contents string) {
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write file : %s", err)
+	}
+
+	if err := os.Rename(tmp, filename); err != nil {
+		t.Fatalf("Failed to rename file : %s", err)
+	}
+}
+
+func TestFileWatcher(t *testing.T) {
+	filename := writeTestFile(t, "config.json", `{"name": "first"}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store, err := LoadStore(ctx, &testLayeredConfig{}, WithConfigFile(filename),
+		WithParamName(""))
+	if err != nil {
+		t.Fatalf("Failed to load store : %s", err)
+	}
+
+	w, err := NewFileWatcher(store, filename, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create watcher : %s", err)
+	}
+
+	if name := store.Get().(*testLayeredConfig).Name; name != "first" {
+		t.Fatalf("Wrong initial name : got %s, want %s", name, "first")
+	}
+
+	changes := make(chan [2]string, 10)
+	store.Subscribe(func(old, new interface{}) {
+		changes <- [2]string{old.(*testLayeredConfig).Name, new.(*testLayeredConfig).Name}
+	})
+
+	go w.Run(ctx)
+
+	replaceTestFile(t, filename, `{"name": "second"}`)
+
+	select {
+	case change := <-changes:
+		if change[0] != "first" || change[1] != "second" {
+			t.Errorf("Wrong change : got %v, want %v", change, [2]string{"first", "second"})
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for reload")
+	}
+
+	// A file that fails to parse keeps the last good config.
+	replaceTestFile(t, filename, `{"name": `)
+	time.Sleep(100 * time.Millisecond)
+
+	if name := store.Get().(*testLayeredConfig).Name; name != "second" {
+		t.Errorf("Wrong name after bad file : got %s, want %s", name, "second")
+	}
+
+	select {
+	case change := <-changes:
+		t.Errorf("Unexpected change after bad file : %v", change)
+	default:
+	}
+}
+
+func TestFileWatcher_Symlink(t *testing.T) {
+	first := writeTestFile(t, "first.json", `{"name": "first"}`)
+	dir := filepath.Dir(first)
+	second := filepath.Join(dir, "second.json")
+	if err := ioutil.WriteFile(second, []byte(`{"name": "second"}`), 0644); err != nil {
+		t.Fatalf("Failed to write file : %s", err)
+	}
+
+	link := filepath.Join(dir, "config.json")
+	if err := os.Symlink(first, link); err != nil {
+		t.Fatalf("Failed to create symlink : %s", err)
+	}
+
+	ctx := context.Background()
+	store, err := LoadStore(ctx, &testLayeredConfig{}, WithConfigFile(link), WithParamName(""))
+	if err != nil {
+		t.Fatalf("Failed to load store : %s", err)
+	}
+
+	w, err := NewFileWatcher(store, link, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create watcher : %s", err)
+	}
+
+	// Swap the symlink the way a ConfigMap volume does.
+	tmpLink := link + ".tmp"
+	if err := os.Symlink(second, tmpLink); err != nil {
+		t.Fatalf("Failed to create symlink : %s", err)
+	}
+	if err := os.Rename(tmpLink, link); err != nil {
+		t.Fatalf("Failed to rename symlink : %s", err)
+	}
+
+	changed, err := w.check(ctx)
+	if err != nil {
+		t.Fatalf("Failed to check : %s", err)
+	}
+
+	if !changed {
+		t.Fatalf("Symlink swap should have reloaded")
+	}
+
+	if name := store.Get().(*testLayeredConfig).Name; name != "second" {
+		t.Errorf("Wrong name : got %s, want %s", name, "second")
+	}
+}